@@ -1,16 +1,44 @@
 package http
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrServerClosed = errors.New("server closed")
+
+// ErrResponseWritten is returned when a handler changes the status or headers of a response that has already been sent.
+var ErrResponseWritten = errors.New("response already written")
+
+// errNoRequest is returned when a connection is closed, or its read deadline passes, before any of a request is sent.
+var errNoRequest = errors.New("no request received")
+
+// errSlowHeaders is returned when the request line and headers arrive slower than ParseOptions.MinHeaderReadRate.
+var errSlowHeaders = errors.New("request headers sent too slowly")
 
 type ClientError struct {
 	message string
 	status  int
+	err     error
 }
 
 func (e ClientError) Error() string {
 	return fmt.Sprintf("[Client error]: %s", e.message)
 }
 
+// Status returns the status code the error should be answered with.
+func (e ClientError) Status() int {
+	if e.status == 0 {
+		return StatusBadRequest
+	}
+
+	return e.status
+}
+
+func (e ClientError) Unwrap() error {
+	return e.err
+}
+
 type ServerError struct {
 	message string
 	status  int
@@ -19,3 +47,28 @@ type ServerError struct {
 func (e ServerError) Error() string {
 	return fmt.Sprintf("[Server error]: %s", e.message)
 }
+
+// HeaderError reports a request header whose value could not be parsed.
+type HeaderError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e HeaderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// Errors wrapped by the HeaderError for an Allow header that could not be parsed.
+var (
+	// ErrEmptyAllow is returned when an Allow header lists no methods at all.
+	ErrEmptyAllow = errors.New("must include at least one method")
+	// ErrEmptyMethod is returned when an element of an Allow header is empty.
+	ErrEmptyMethod = errors.New("empty method")
+	// ErrInvalidMethod is returned when an Allow header lists a method the server does not support.
+	ErrInvalidMethod = errors.New("includes unsupported methods")
+)
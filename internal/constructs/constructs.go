@@ -134,35 +134,37 @@ func ValidateText(t string) error {
 	return nil
 }
 
-func ParseDate(d string) (time.Time, error) {
-	var date time.Time
+var gmt = time.FixedZone("GMT", 0)
 
-	res, err := time.Parse(time.RFC850, d)
+// ParseDate parses d using the first of the RFC 1123, RFC 850 and asctime formats that matches it. The result is always
+// in the GMT fixed zone. RFC 1123 and RFC 850 dates name their zone, which must be GMT; asctime dates carry no zone, so
+// they are assumed to be GMT.
+func ParseDate(d string) (time.Time, error) {
+	date, err := time.Parse(time.RFC1123, d)
 	if err == nil {
-		date = res
+		return toGmt(date, "RFC 1123", d)
 	}
 
-	res, err = time.Parse(time.RFC1123, d)
+	date, err = time.Parse(time.RFC850, d)
 	if err == nil {
-		date = res
+		return toGmt(date, "RFC 850", d)
 	}
 
-	res, err = time.Parse(time.ANSIC, d)
+	date, err = time.Parse(time.ANSIC, d)
 	if err == nil {
-		date = res.In(time.FixedZone("GMT", 0))
+		return date.In(gmt), nil
 	}
 
-	if date.IsZero() {
-		return date, fmt.Errorf("could not parse date: %s", d)
-	}
+	return time.Time{}, fmt.Errorf("could not parse date: %s", d)
+}
 
+func toGmt(date time.Time, format string, d string) (time.Time, error) {
 	tz, _ := date.Zone()
 	if tz != "GMT" {
-		return date, fmt.Errorf("timezone must be GMT: %s", d)
+		return date, fmt.Errorf("%s date has timezone %s, must be GMT: %s", format, tz, d)
 	}
 
-	return date, nil
-
+	return date.In(gmt), nil
 }
 
 func validateQdText(t string) error {
@@ -288,6 +290,48 @@ func ValidateScheme(s string) error {
 	return nil
 }
 
+func ValidateToken68(t string) error {
+	if len(t) == 0 {
+		return fmt.Errorf("token68 cannot be empty")
+	}
+
+	i := 0
+	for i < len(t) {
+		c := HttpByte(t[i])
+		if !c.IsAlpha() && !c.IsNumeric() && !slices.Contains([]HttpByte{'-', '.', '_', '~', '+', '/'}, c) {
+			break
+		}
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Errorf("token68 must begin with a non-padding character (%s)", t)
+	}
+
+	for i < len(t) {
+		if t[i] != '=' {
+			return fmt.Errorf("token68 contains invalid character (%s)", t)
+		}
+		i++
+	}
+
+	return nil
+}
+
+func (b HttpByte) IsCookieOctet() bool {
+	return b == 0x21 || (b >= 0x23 && b <= 0x2B) || (b >= 0x2D && b <= 0x3A) || (b >= 0x3C && b <= 0x5B) || (b >= 0x5D && b <= 0x7E)
+}
+
+func ValidateCookieValue(v string) error {
+	for _, c := range []byte(v) {
+		if !HttpByte(c).IsCookieOctet() {
+			return fmt.Errorf("cookie value contains invalid character (%s)", v)
+		}
+	}
+
+	return nil
+}
+
 type Scheme string
 
 func (s Scheme) Validate() error {
@@ -1014,6 +1014,77 @@ func TestParseDate(t *testing.T) {
 	}
 }
 
+func TestParseDate_zone(t *testing.T) {
+	tests := []struct {
+		name    string
+		dateVal string
+	}{
+		{
+			name:    "RFC 1123",
+			dateVal: "Sun, 06 Nov 1994 08:49:37 GMT",
+		},
+		{
+			name:    "RFC 850",
+			dateVal: "Sunday, 06-Nov-94 08:49:37 GMT",
+		},
+		{
+			name:    "asctime",
+			dateVal: "Sun Nov  6 08:49:37 1994",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ParseDate(tt.dateVal)
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err.Error())
+			}
+
+			assert.Equal(t, res.Location(), gmt)
+		})
+	}
+}
+
+func TestParseDate_nonGmt(t *testing.T) {
+	tests := []struct {
+		name          string
+		dateVal       string
+		expected      time.Time
+		expectedError string
+	}{
+		{
+			name:     "asctime is assumed GMT",
+			dateVal:  "Sun Nov  6 08:49:37 1994",
+			expected: time.Date(1994, 11, 6, 8, 49, 37, 0, gmt),
+		},
+		{
+			name:          "RFC 1123 in PST",
+			dateVal:       "Sun, 06 Nov 1994 08:49:37 PST",
+			expectedError: "RFC 1123 date has timezone PST, must be GMT: Sun, 06 Nov 1994 08:49:37 PST",
+		},
+		{
+			name:          "RFC 850 in UTC",
+			dateVal:       "Sunday, 06-Nov-94 08:49:37 UTC",
+			expectedError: "RFC 850 date has timezone UTC, must be GMT: Sunday, 06-Nov-94 08:49:37 UTC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ParseDate(tt.dateVal)
+			ok := assert.ErrorStatus(t, err, tt.expectedError != "")
+			if !ok {
+				if err != nil {
+					assert.Equal(t, err.Error(), tt.expectedError)
+				}
+				return
+			}
+
+			assert.DateEqual(t, res, tt.expected)
+		})
+	}
+}
+
 func TestValidateComment(t *testing.T) {
 	tests := []validateCheck{
 		{
@@ -1112,3 +1183,107 @@ func TestValidateScheme(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateToken68(t *testing.T) {
+	tests := []validateCheck{
+		{
+			name:        "Standard token",
+			string:      "mF_9.B5f-4.1JqM",
+			expectError: false,
+		},
+		{
+			name:        "Token with padding",
+			string:      "YWJj+/~d==",
+			expectError: false,
+		},
+		{
+			name:        "Empty token",
+			string:      "",
+			expectError: true,
+		},
+		{
+			name:        "Only padding",
+			string:      "==",
+			expectError: true,
+		},
+		{
+			name:        "Padding in the middle",
+			string:      "ab=cd",
+			expectError: true,
+		},
+		{
+			name:        "Embedded space",
+			string:      "abc def",
+			expectError: true,
+		},
+		{
+			name:        "Control character",
+			string:      "abc\x01",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateToken68(tt.string)
+			assert.ErrorStatus(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestValidateCookieValue(t *testing.T) {
+	tests := []validateCheck{
+		{
+			name:        "Standard value",
+			string:      "abc123",
+			expectError: false,
+		},
+		{
+			name:        "Value with allowed symbols",
+			string:      "a!#$%&'()*+-./:<=>?@[]^_`{|}~",
+			expectError: false,
+		},
+		{
+			name:        "Empty value",
+			string:      "",
+			expectError: false,
+		},
+		{
+			name:        "Value with space",
+			string:      "a b",
+			expectError: true,
+		},
+		{
+			name:        "Value with double quote",
+			string:      `a"b`,
+			expectError: true,
+		},
+		{
+			name:        "Value with comma",
+			string:      "a,b",
+			expectError: true,
+		},
+		{
+			name:        "Value with semicolon",
+			string:      "a;b",
+			expectError: true,
+		},
+		{
+			name:        "Value with backslash",
+			string:      `a\b`,
+			expectError: true,
+		},
+		{
+			name:        "Value with control character",
+			string:      "a\x01b",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCookieValue(tt.string)
+			assert.ErrorStatus(t, err, tt.expectError)
+		})
+	}
+}
@@ -100,3 +100,23 @@ func TrimRight(s string) string {
 func Trim(s string) string {
 	return TrimRight(TrimLeft(s))
 }
+
+// Unfold replaces each line fold in s, a CRLF followed by one or more SP or HT, with a single SP.
+func Unfold(s string) string {
+	res := []byte{}
+
+	i := 0
+	for i < len(s) {
+		isNewLine, _, max := NewLine(s, i)
+		if isNewLine {
+			res = append(res, SP)
+			i = max
+			continue
+		}
+
+		res = append(res, s[i])
+		i++
+	}
+
+	return string(res)
+}
@@ -405,3 +405,48 @@ func TestTrim(t *testing.T) {
 		})
 	}
 }
+
+func TestUnfold(t *testing.T) {
+	tests := []struct {
+		name     string
+		string   string
+		expected string
+	}{
+		{
+			name:     "No folds",
+			string:   "a b\tc",
+			expected: "a b\tc",
+		},
+		{
+			name:     "Single fold",
+			string:   "a\r\n b",
+			expected: "a b",
+		},
+		{
+			name:     "Fold with several SP and HT",
+			string:   "a\r\n \t  b",
+			expected: "a b",
+		},
+		{
+			name:     "Multiple folds",
+			string:   "a\r\n\tb\r\n c",
+			expected: "a b c",
+		},
+		{
+			name:     "Space before fold is kept",
+			string:   "a \r\n b",
+			expected: "a  b",
+		},
+		{
+			name:     "CRLF without SP or HT is not a fold",
+			string:   "a\r\nb",
+			expected: "a\r\nb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Unfold(tt.string), tt.expected)
+		})
+	}
+}
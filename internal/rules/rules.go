@@ -1,11 +1,21 @@
 package rules
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/tony-montemuro/http/internal/constructs"
 	"github.com/tony-montemuro/http/internal/lws"
 )
 
+// QValue is an element of a q-value list, along with its quality.
+type QValue struct {
+	Value string
+	Q     float64
+}
+
 func Extract(s string) []string {
 	rules := []string{}
 
@@ -20,3 +30,84 @@ func Extract(s string) []string {
 
 	return rules
 }
+
+// QList parses a comma separated list of elements, each with an optional q parameter, as used by the Accept family of
+// headers. Elements without a q parameter have a quality of 1. Any other parameters are kept as part of the element's
+// value. The list is returned sorted by descending quality; elements of equal quality keep the order they were sent in.
+func QList(s string) ([]QValue, error) {
+	list := []QValue{}
+
+	for _, rule := range Extract(s) {
+		if len(rule) == 0 {
+			continue
+		}
+
+		parts := strings.Split(rule, ";")
+		value := []string{lws.Trim(parts[0])}
+		q := 1.0
+		hasQ := false
+
+		for _, param := range parts[1:] {
+			param = lws.Trim(param)
+			attribute, v, _ := strings.Cut(param, "=")
+			if strings.ToLower(lws.TrimRight(attribute)) != "q" {
+				value = append(value, param)
+				continue
+			}
+
+			if hasQ {
+				return nil, fmt.Errorf("element has more than one q parameter (%s)", rule)
+			}
+
+			var err error
+			q, err = ParseQValue(lws.TrimLeft(v))
+			if err != nil {
+				return nil, err
+			}
+			hasQ = true
+		}
+
+		if len(value[0]) == 0 {
+			return nil, fmt.Errorf("element has no value (%s)", rule)
+		}
+
+		list = append(list, QValue{Value: strings.Join(value, ";"), Q: q})
+	}
+
+	slices.SortStableFunc(list, func(a, b QValue) int {
+		switch {
+		case a.Q > b.Q:
+			return -1
+		case a.Q < b.Q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return list, nil
+}
+
+// ParseQValue parses a qvalue: 0 or 1, optionally followed by a decimal point and up to 3 digits, no greater than 1.
+func ParseQValue(data string) (float64, error) {
+	if len(data) == 0 || len(data) > 5 || (data[0] != '0' && data[0] != '1') {
+		return 0, fmt.Errorf("malformed q value (%s)", data)
+	}
+
+	if len(data) > 1 && data[1] != '.' {
+		return 0, fmt.Errorf("malformed q value (%s)", data)
+	}
+
+	for _, c := range data[min(len(data), 2):] {
+		if !constructs.HttpByte(c).IsNumeric() {
+			return 0, fmt.Errorf("malformed q value (%s)", data)
+		}
+	}
+
+	q, err := strconv.ParseFloat(data, 64)
+	if err != nil || q > 1 {
+		return 0, fmt.Errorf("q value must be between 0 and 1 (%s)", data)
+	}
+
+	return q, nil
+}
@@ -35,3 +35,159 @@ func TestExtract(t *testing.T) {
 		})
 	}
 }
+
+func TestQList(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        string
+		expected    []QValue
+		expectError bool
+	}{
+		{
+			name:        "Default q",
+			list:        "gzip, compress",
+			expected:    []QValue{{Value: "gzip", Q: 1}, {Value: "compress", Q: 1}},
+			expectError: false,
+		},
+		{
+			name:        "Sorted by descending q",
+			list:        "da;q=0.5, en-gb;q=0.8, en",
+			expected:    []QValue{{Value: "en", Q: 1}, {Value: "en-gb", Q: 0.8}, {Value: "da", Q: 0.5}},
+			expectError: false,
+		},
+		{
+			name:        "Ties keep their order",
+			list:        "b;q=0.5, a;q=0.9, c;q=0.5, d;q=0.9",
+			expected:    []QValue{{Value: "a", Q: 0.9}, {Value: "d", Q: 0.9}, {Value: "b", Q: 0.5}, {Value: "c", Q: 0.5}},
+			expectError: false,
+		},
+		{
+			name:        "LWS and upper case q",
+			list:        "iso-8859-5 ;\r\n Q = 0.2 ,\t*",
+			expected:    []QValue{{Value: "*", Q: 1}, {Value: "iso-8859-5", Q: 0.2}},
+			expectError: false,
+		},
+		{
+			name:        "Other parameters are kept",
+			list:        "text/html;level=1;q=0.7, text/plain",
+			expected:    []QValue{{Value: "text/plain", Q: 1}, {Value: "text/html;level=1", Q: 0.7}},
+			expectError: false,
+		},
+		{
+			name:        "Empty elements are skipped",
+			list:        ", gzip,, ",
+			expected:    []QValue{{Value: "gzip", Q: 1}},
+			expectError: false,
+		},
+		{
+			name:        "q greater than one",
+			list:        "gzip;q=1.5",
+			expectError: true,
+		},
+		{
+			name:        "q with too many decimals",
+			list:        "gzip;q=0.1234",
+			expectError: true,
+		},
+		{
+			name:        "q without a value",
+			list:        "gzip;q",
+			expectError: true,
+		},
+		{
+			name:        "Repeated q",
+			list:        "gzip;q=0.5;q=0.6",
+			expectError: true,
+		},
+		{
+			name:        "Missing value",
+			list:        ";q=0.5",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := QList(tt.list)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
+func TestParseQValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    float64
+		expectError bool
+	}{
+		{
+			name:        "Zero",
+			input:       "0",
+			expected:    0,
+			expectError: false,
+		},
+		{
+			name:        "One",
+			input:       "1",
+			expected:    1,
+			expectError: false,
+		},
+		{
+			name:        "Three decimal places",
+			input:       "0.125",
+			expected:    0.125,
+			expectError: false,
+		},
+		{
+			name:        "One with trailing zeros",
+			input:       "1.00",
+			expected:    1,
+			expectError: false,
+		},
+		{
+			name:        "Empty",
+			input:       "",
+			expectError: true,
+		},
+		{
+			name:        "Greater than one",
+			input:       "1.001",
+			expectError: true,
+		},
+		{
+			name:        "Leading dot",
+			input:       ".5",
+			expectError: true,
+		},
+		{
+			name:        "Negative",
+			input:       "-0.5",
+			expectError: true,
+		},
+		{
+			name:        "Exponent",
+			input:       "1e-1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ParseQValue(tt.input)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, res, tt.expected)
+		})
+	}
+}
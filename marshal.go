@@ -1,10 +1,13 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"compress/lzw"
+	"compress/zlib"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,21 +20,82 @@ type marshaler interface {
 	marshal() []byte
 }
 
+type marshalerFunc func() []byte
+
+func (f marshalerFunc) marshal() []byte {
+	return f()
+}
+
 func (r response) marshal() []byte {
+	marshaled := r.marshalHead()
+	return append(marshaled, r.sentBody()...)
+}
+
+// writeTo writes the marshaled response to w, without first copying the head and body into one slice.
+func (r response) writeTo(w *bufio.Writer) error {
+	_, err := w.Write(r.marshalHead())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(r.sentBody())
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// marshalHead marshals the status line and headers.
+func (r response) marshalHead() []byte {
 	var marshaled []byte
 
 	line := r.code.marshal()
 	marshaled = append(marshaled, line...)
 
-	headers := r.headers.marshal(len(r.body) > 0)
-	marshaled = append(marshaled, headers...)
+	var body []byte
+	if r.code.allowsBody() {
+		body = r.body
+	}
+
+	// the body itself is the source of truth for its length, since it may have been set without updating the header
+	h := r.headers
+	if len(body) > 0 {
+		h.contentLength = ContentLength(len(body))
+	}
+
+	// a kept-alive connection has no other way of framing the response, so Content-Length is always sent
+	hasBody := len(body) > 0 || (r.code.allowsBody() && r.headers.connection.Has("keep-alive"))
+	headers := h.marshal(hasBody)
+	return append(marshaled, headers...)
+}
+
+// sentBody returns the bytes that follow the head on the wire, which is nothing for a HEAD request, or for a status code
+// that does not allow a body.
+func (r response) sentBody() []byte {
+	if r.head || !r.code.allowsBody() {
+		return nil
+	}
 
-	marshaled = append(marshaled, r.body...)
-	return marshaled
+	return r.body
 }
 
 func (c code) marshal() []byte {
-	return fmt.Appendf([]byte{}, "HTTP/1.0 %d %s%s", c, StatusText(int(c)), constructs.Crlf)
+	return c.marshalVersion("1.0")
+}
+
+// marshalVersion marshals the status line with an HTTP version other than 1.0, which responses that use HTTP/1.1
+// features, like the chunked transfer coding, must be sent with.
+func (c code) marshalVersion(version string) []byte {
+	return fmt.Appendf([]byte{}, "HTTP/%s %d %s%s", version, c, StatusText(int(c)), constructs.Crlf)
+}
+
+func (c code) allowsBody() bool {
+	if c >= 100 && c < 200 {
+		return false
+	}
+
+	return c != StatusNoContent && c != StatusNotModified
 }
 
 func (h responseHeaders) marshal(hasBody bool) []byte {
@@ -39,24 +103,38 @@ func (h responseHeaders) marshal(hasBody bool) []byte {
 
 	headers = append(headers, marshalHeader("Date", h.date)...)
 	headers = append(headers, marshalHeader("Pragma", h.pragma)...)
+	headers = append(headers, marshalHeader("Cache-Control", h.cacheControl)...)
+	headers = append(headers, marshalHeader("Connection", h.connection)...)
+	headers = append(headers, marshalHeader("MIME-Version", h.mimeVersion)...)
 
 	if h.location != nil {
-		headers = append(headers, marshalHeader("Location", h.location)...)
+		headers = append(headers, marshalHeader("Location", marshalerFunc(h.location.marshalEncoded))...)
 	}
 
+	headers = append(headers, marshalHeader("Retry-After", h.retryAfter)...)
 	headers = append(headers, marshalHeader("Server", h.server)...)
-	headers = append(headers, marshalHeader("WWW-Authenticate", h.wwwAuthenticate)...)
+
+	for _, c := range h.wwwAuthenticate {
+		headers = append(headers, marshalHeader("WWW-Authenticate", c)...)
+	}
+
 	headers = append(headers, marshalHeader("Allow", h.allow)...)
 	headers = append(headers, marshalHeader("Content-Encoding", h.contentEncoding)...)
+	headers = append(headers, marshalHeader("Transfer-Encoding", h.transferEncoding)...)
 
 	if hasBody {
 		headers = append(headers, marshalHeader("Content-Length", h.contentLength)...)
 	}
 
+	headers = append(headers, marshalHeader("Content-Range", h.contentRange)...)
 	headers = append(headers, marshalHeader("Content-Type", h.contentType)...)
 	headers = append(headers, marshalHeader("Expires", h.expires)...)
 	headers = append(headers, marshalHeader("Last-Modified", h.lastModified)...)
 
+	for _, cookie := range h.setCookies {
+		headers = append(headers, marshalHeader("Set-Cookie", cookie)...)
+	}
+
 	for _, name := range getSortedKeys(h.unrecognized) {
 		headers = fmt.Appendf(headers, "%s: %s%s", name, h.unrecognized[name], constructs.Crlf)
 	}
@@ -75,6 +153,14 @@ func marshalHeader(n string, m marshaler) []byte {
 
 }
 
+func (mv MimeVersion) marshal() []byte {
+	if mv == (MimeVersion{}) {
+		return []byte{}
+	}
+
+	return fmt.Appendf([]byte{}, "%d.%d", mv.Major, mv.Minor)
+}
+
 func (t MessageTime) marshal() []byte {
 	var res []byte
 
@@ -101,6 +187,28 @@ func (p PragmaDirectives) marshal() []byte {
 	return []byte(strings.Join(parts, " "))
 }
 
+func (cc CacheControl) marshal() []byte {
+	var parts []string
+
+	for _, flag := range getSortedKeys(cc.Flags) {
+		parts = append(parts, flag)
+	}
+
+	for _, name := range getSortedKeys(cc.Options) {
+		value := cc.Options[name]
+		if constructs.ValidateToken(value) != nil {
+			value = fmt.Sprintf(`"%s"`, value)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	return []byte(strings.Join(parts, ", "))
+}
+
+func (co ConnectionOptions) marshal() []byte {
+	return []byte(strings.Join(co, ", "))
+}
+
 func (pv ProductVersion) marshal() []byte {
 	res := []byte(pv.Product)
 
@@ -112,7 +220,13 @@ func (pv ProductVersion) marshal() []byte {
 }
 
 func (u AbsoluteUri) marshal() []byte {
-	return fmt.Appendf([]byte{}, "%s:%s", u.Scheme, u.Path)
+	res := fmt.Appendf([]byte{}, "%s:%s", u.Scheme, u.Path)
+
+	if len(u.Fragment) > 0 {
+		res = fmt.Appendf(res, "#%s", u.Fragment)
+	}
+
+	return res
 }
 
 func (u RelativeUri) marshal() []byte {
@@ -135,6 +249,111 @@ func (u RelativeUri) marshal() []byte {
 	return res
 }
 
+// marshalEncoded is like marshal, but percent-encodes any byte the scheme-specific part cannot carry literally.
+func (u AbsoluteUri) marshalEncoded() []byte {
+	res := fmt.Appendf([]byte{}, "%s:%s", u.Scheme, percentEncode(u.Path, isUriByte))
+
+	if len(u.Fragment) > 0 {
+		res = fmt.Appendf(res, "#%s", percentEncode(u.Fragment, isUriByte))
+	}
+
+	return res
+}
+
+// marshalEncoded is like marshal, but percent-encodes any byte its component cannot carry literally.
+func (u RelativeUri) marshalEncoded() []byte {
+	var res []byte
+
+	if len(u.NetLoc) > 0 {
+		res = fmt.Appendf(res, "//%s", u.NetLoc)
+	}
+
+	res = append(res, percentEncode(u.Path, isPathByte)...)
+	for _, param := range u.Params {
+		res = fmt.Appendf(res, ";%s", percentEncode(param, isPathByte))
+	}
+
+	if len(u.Query) > 0 {
+		res = fmt.Appendf(res, "?%s", percentEncode(u.Query, isUriByte))
+	}
+
+	return res
+}
+
+func isPathByte(b constructs.HttpByte) bool {
+	return b.IsPChar() || b == constructs.ByteSeparator
+}
+
+func isUriByte(b constructs.HttpByte) bool {
+	return b.IsReserved() || b.IsUnreserved()
+}
+
+// percentEncode escapes every byte of data that allowed rejects, along with any byte outside US-ASCII. Escape sequences
+// already in data are kept as they are, rather than escaping their %.
+func percentEncode(data []byte, allowed func(constructs.HttpByte) bool) []byte {
+	var res []byte
+
+	for i := 0; i < len(data); i++ {
+		b := constructs.HttpByte(data[i])
+
+		if b.IsEscape() && i+2 < len(data) && constructs.HttpByte(data[i+1]).IsHex() && constructs.HttpByte(data[i+2]).IsHex() {
+			res = append(res, data[i:i+3]...)
+			i += 2
+			continue
+		}
+
+		if b.IsEscape() || !b.IsUSAscii() || !allowed(b) {
+			res = fmt.Appendf(res, "%%%02X", data[i])
+			continue
+		}
+
+		res = append(res, data[i])
+	}
+
+	return res
+}
+
+func (ra retryAfter) marshal() []byte {
+	if ra.isDelay {
+		return []byte(strconv.FormatUint(ra.seconds, 10))
+	}
+
+	return ra.date.marshal()
+}
+
+func (c Cookie) marshal() []byte {
+	res := fmt.Appendf([]byte{}, "%s=%s", c.Name, c.Value)
+
+	if len(c.Path) > 0 {
+		res = fmt.Appendf(res, "; Path=%s", c.Path)
+	}
+
+	if len(c.Domain) > 0 {
+		res = fmt.Appendf(res, "; Domain=%s", c.Domain)
+	}
+
+	expires := MessageTime{date: c.Expires}.marshal()
+	if len(expires) > 0 {
+		res = fmt.Appendf(res, "; Expires=%s", expires)
+	}
+
+	if c.MaxAge > 0 {
+		res = fmt.Appendf(res, "; Max-Age=%d", c.MaxAge)
+	} else if c.MaxAge < 0 {
+		res = append(res, "; Max-Age=0"...)
+	}
+
+	if c.Secure {
+		res = append(res, "; Secure"...)
+	}
+
+	if c.HttpOnly {
+		res = append(res, "; HttpOnly"...)
+	}
+
+	return res
+}
+
 func (s server) marshal() []byte {
 	var parts []string
 
@@ -175,6 +394,10 @@ func (m Methods) marshal() []byte {
 	return []byte(strings.Join(methods, ", "))
 }
 
+func (tc transferCoding) marshal() []byte {
+	return []byte(tc)
+}
+
 func (ce ContentEncoding) marshal() []byte {
 	var res []byte
 
@@ -189,6 +412,10 @@ func (cl ContentLength) marshal() []byte {
 	return []byte(strconv.FormatUint(uint64(cl), 10))
 }
 
+func (cr contentRange) marshal() []byte {
+	return []byte(cr)
+}
+
 func (ct ContentType) marshal() []byte {
 	var res []byte
 
@@ -221,6 +448,10 @@ func encodeRequestBody(body []byte, encoding ContentEncoding) ([]byte, error) {
 		res, err = gzipEncode(body)
 	case ContentEncodingXCompress, ContentEncodingCompress:
 		res, err = compressEncode(body)
+	case ContentEncodingDeflate:
+		res, err = deflateEncode(body)
+	case ContentEncodingIdentity:
+		res, err = body, nil
 	default:
 		res, err = body, nil
 	}
@@ -247,7 +478,7 @@ func gzipEncode(data []byte) ([]byte, error) {
 
 func compressEncode(data []byte) ([]byte, error) {
 	var b bytes.Buffer
-	w := lzw.NewWriter(&b, lzw.LSB, 8)
+	w := lzw.NewWriter(&b, compressOrder, compressLitWidth)
 
 	_, err := w.Write(data)
 	if err != nil {
@@ -257,3 +488,39 @@ func compressEncode(data []byte) ([]byte, error) {
 	err = w.Close()
 	return b.Bytes(), err
 }
+
+// deflateEncode writes data in the zlib format, which is what the deflate coding has always meant on the wire, rather
+// than a raw deflate stream.
+func deflateEncode(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+
+	_, err := w.Write(data)
+	if err != nil {
+		return b.Bytes(), err
+	}
+
+	err = w.Close()
+	return b.Bytes(), err
+}
+
+func newEncodingWriter(w io.Writer, encoding ContentEncoding) io.WriteCloser {
+	switch encoding {
+	case ContentEncodingXGzip, ContentEncodingGZip:
+		return gzip.NewWriter(w)
+	case ContentEncodingXCompress, ContentEncodingCompress:
+		return lzw.NewWriter(w, compressOrder, compressLitWidth)
+	case ContentEncodingDeflate:
+		return zlib.NewWriter(w)
+	default:
+		return nopWriteCloser{w}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -43,7 +43,50 @@ func TestResponse_marshal(t *testing.T) {
 			},
 			expected: []byte(
 				"HTTP/1.0 200 OK\r\n" +
-					"Content-Length: 0\r\n" +
+					"Content-Length: 11\r\n" +
+					"\r\n" +
+					"hello world",
+			),
+		},
+		{
+			name: "Response to HEAD request",
+			response: response{
+				code: 200,
+				body: responseBody("hello world"),
+				head: true,
+			},
+			expected: []byte(
+				"HTTP/1.0 200 OK\r\n" +
+					"Content-Length: 11\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "Location is percent-encoded",
+			response: response{
+				code: 301,
+				headers: responseHeaders{
+					location: AbsoluteUri{Scheme: []byte("http"), Path: []byte("//example.com/new home?a=1%202")},
+				},
+			},
+			expected: []byte(
+				"HTTP/1.0 301 Moved Permanently\r\n" +
+					"Location: http://example.com/new%20home?a=1%202\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "Stale Content-Length is replaced by body length",
+			response: response{
+				code: 200,
+				headers: responseHeaders{
+					contentLength: 3,
+				},
+				body: responseBody(append([]byte("hello"), " world"...)),
+			},
+			expected: []byte(
+				"HTTP/1.0 200 OK\r\n" +
+					"Content-Length: 11\r\n" +
 					"\r\n" +
 					"hello world",
 			),
@@ -92,9 +135,11 @@ func TestResponse_marshal(t *testing.T) {
 			response: response{
 				code: 401,
 				headers: responseHeaders{
-					wwwAuthenticate: challenge{
-						scheme: "Basic",
-						realm:  `"Restricted"`,
+					wwwAuthenticate: []challenge{
+						{
+							scheme: "Basic",
+							realm:  `"Restricted"`,
+						},
 					},
 				},
 			},
@@ -164,16 +209,80 @@ func TestResponse_marshal(t *testing.T) {
 			),
 		},
 		{
-			name: "204 No Content with body largely ignored",
+			name: "204 No Content with body ignored",
 			response: response{
 				code: 204,
+				headers: responseHeaders{
+					contentLength: 17,
+				},
 				body: responseBody("should not matter"),
 			},
 			expected: []byte(
 				"HTTP/1.0 204 No Content\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "304 Not Modified with body ignored",
+			response: response{
+				code: 304,
+				headers: responseHeaders{
+					date:          MessageTime{date: t1},
+					contentLength: 17,
+				},
+				body: responseBody("should not matter"),
+			},
+			expected: []byte(
+				"HTTP/1.0 304 Not Modified\r\n" +
+					"Date: Tue, 02 Jan 2024 15:04:05 GMT\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "Multiple cookies",
+			response: response{
+				code: 200,
+				headers: responseHeaders{
+					setCookies: []Cookie{
+						{Name: "a", Value: "1"},
+						{Name: "b", Value: "2", HttpOnly: true},
+					},
+				},
+			},
+			expected: []byte(
+				"HTTP/1.0 200 OK\r\n" +
+					"Set-Cookie: a=1\r\n" +
+					"Set-Cookie: b=2; HttpOnly\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "503 with Retry-After",
+			response: response{
+				code: 503,
+				headers: responseHeaders{
+					retryAfter: retryAfter{seconds: 30, isDelay: true},
+				},
+			},
+			expected: []byte(
+				"HTTP/1.0 503 Service Unavailable\r\n" +
+					"Retry-After: 30\r\n" +
+					"\r\n",
+			),
+		},
+		{
+			name: "Keep-alive response with empty body",
+			response: response{
+				code: 200,
+				headers: responseHeaders{
+					connection: ConnectionOptions{"keep-alive"},
+				},
+			},
+			expected: []byte(
+				"HTTP/1.0 200 OK\r\n" +
+					"Connection: keep-alive\r\n" +
 					"Content-Length: 0\r\n" +
-					"\r\n" +
-					"should not matter",
+					"\r\n",
 			),
 		},
 		{
@@ -194,7 +303,7 @@ func TestResponse_marshal(t *testing.T) {
 							"charset": `"utf-8"`,
 						},
 					},
-					contentLength: 13,
+					contentLength: 14,
 				},
 				body: responseBody("<h1>Hello</h1>"),
 			},
@@ -202,7 +311,7 @@ func TestResponse_marshal(t *testing.T) {
 				"HTTP/1.0 200 OK\r\n" +
 					"Date: Tue, 02 Jan 2024 15:04:05 GMT\r\n" +
 					"Server: myserver/2.1\r\n" +
-					"Content-Length: 13\r\n" +
+					"Content-Length: 14\r\n" +
 					`Content-Type: text/html;charset="utf-8"` + "\r\n" +
 					"\r\n" +
 					"<h1>Hello</h1>",
@@ -312,9 +421,11 @@ func TestResponseHeaders_marshal(t *testing.T) {
 		{
 			name: "WWW-Authenticate only with body",
 			headers: responseHeaders{
-				wwwAuthenticate: challenge{
-					scheme: "Basic",
-					realm:  `"Restricted"`,
+				wwwAuthenticate: []challenge{
+					{
+						scheme: "Basic",
+						realm:  `"Restricted"`,
+					},
 				},
 			},
 			hasBody: true,
@@ -495,6 +606,49 @@ func TestPragmaDirectives_marshal(t *testing.T) {
 	}
 }
 
+func TestCacheControl_marshal(t *testing.T) {
+	tests := []marshalTest{
+		{
+			name: "Single flag",
+			marshaler: CacheControl{
+				Flags: map[string]bool{"no-store": true},
+			},
+			expected: []byte("no-store"),
+		},
+		{
+			name: "Flags & options",
+			marshaler: CacheControl{
+				Flags: map[string]bool{"no-transform": true, "no-cache": true},
+				Options: map[string]string{
+					"max-age": "60",
+				},
+			},
+			expected: []byte("no-cache, no-transform, max-age=60"),
+		},
+		{
+			name: "Option requiring quotes",
+			marshaler: CacheControl{
+				Options: map[string]string{
+					"community": "a b",
+				},
+			},
+			expected: []byte(`community="a b"`),
+		},
+		{
+			name:      "Empty directives",
+			marshaler: CacheControl{},
+			expected:  []byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.marshaler.marshal()
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
 func TestProductVersion_marshal(t *testing.T) {
 	tests := []marshalTest{
 		{
@@ -569,6 +723,15 @@ func TestAbsoluteUri_marshal(t *testing.T) {
 			},
 			expected: []byte("soap-beep+v2://api/endpoint"),
 		},
+		{
+			name: "With fragment",
+			marshaler: &AbsoluteUri{
+				Scheme:   []byte("http"),
+				Path:     []byte("//example.com/index.html"),
+				Fragment: []byte("top"),
+			},
+			expected: []byte("http://example.com/index.html#top"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -658,6 +821,143 @@ func TestRelativeUri_marshal(t *testing.T) {
 	}
 }
 
+func TestMimeVersion_marshal(t *testing.T) {
+	tests := []marshalTest{
+		{
+			name:      "Version 1.0",
+			marshaler: MimeVersion{Major: 1, Minor: 0},
+			expected:  []byte("1.0"),
+		},
+		{
+			name:      "Multi-digit minor version",
+			marshaler: MimeVersion{Major: 2, Minor: 11},
+			expected:  []byte("2.11"),
+		},
+		{
+			name:      "Unset",
+			marshaler: MimeVersion{},
+			expected:  []byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.marshaler.marshal()
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
+func TestRelativeUri_marshalEncoded(t *testing.T) {
+	tests := []marshalTest{
+		{
+			name:      "Path with space",
+			marshaler: marshalerFunc(RelativeUri{Path: []byte("/my files/report.pdf")}.marshalEncoded),
+			expected:  []byte("/my%20files/report.pdf"),
+		},
+		{
+			name:      "Query with ampersand",
+			marshaler: marshalerFunc(RelativeUri{Path: []byte("/search"), Query: []byte("q=salt & pepper&page=2")}.marshalEncoded),
+			expected:  []byte("/search?q=salt%20&%20pepper&page=2"),
+		},
+		{
+			name:      "Pre-escaped sequence",
+			marshaler: marshalerFunc(RelativeUri{Path: []byte("/a%20b/100%")}.marshalEncoded),
+			expected:  []byte("/a%20b/100%25"),
+		},
+		{
+			name:      "Reserved bytes in path and params",
+			marshaler: marshalerFunc(RelativeUri{Path: []byte("/what?#"), Params: [][]byte{[]byte("a;b")}}.marshalEncoded),
+			expected:  []byte("/what%3F%23;a%3Bb"),
+		},
+		{
+			name:      "Non-ASCII path",
+			marshaler: marshalerFunc(RelativeUri{Path: []byte("/caf\xc3\xa9")}.marshalEncoded),
+			expected:  []byte("/caf%C3%A9"),
+		},
+		{
+			name:      "Network location is left as is",
+			marshaler: marshalerFunc(RelativeUri{NetLoc: []byte("example.com:8080"), Path: []byte("/a b")}.marshalEncoded),
+			expected:  []byte("//example.com:8080/a%20b"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.marshaler.marshal()
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
+func TestRetryAfter_marshal(t *testing.T) {
+	tests := []marshalTest{
+		{
+			name:      "Delay in seconds",
+			marshaler: retryAfter{seconds: 120, isDelay: true},
+			expected:  []byte("120"),
+		},
+		{
+			name:      "Zero delay",
+			marshaler: retryAfter{isDelay: true},
+			expected:  []byte("0"),
+		},
+		{
+			name:      "HTTP date",
+			marshaler: retryAfter{date: MessageTime{date: time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0))}},
+			expected:  []byte("Tue, 02 Jan 2024 15:04:05 GMT"),
+		},
+		{
+			name:      "Unset",
+			marshaler: retryAfter{},
+			expected:  []byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.marshaler.marshal()
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
+func TestCookie_marshal(t *testing.T) {
+	tests := []marshalTest{
+		{
+			name:      "Minimal cookie",
+			marshaler: Cookie{Name: "session", Value: "abc123"},
+			expected:  []byte("session=abc123"),
+		},
+		{
+			name: "All attributes",
+			marshaler: Cookie{
+				Name:     "session",
+				Value:    "abc123",
+				Path:     "/",
+				Domain:   "example.com",
+				Expires:  time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0)),
+				MaxAge:   3600,
+				Secure:   true,
+				HttpOnly: true,
+			},
+			expected: []byte("session=abc123; Path=/; Domain=example.com; Expires=Tue, 02 Jan 2024 15:04:05 GMT; Max-Age=3600; Secure; HttpOnly"),
+		},
+		{
+			name:      "Negative max age",
+			marshaler: Cookie{Name: "session", Value: "", MaxAge: -1},
+			expected:  []byte("session=; Max-Age=0"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.marshaler.marshal()
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
 func TestServer_marshal(t *testing.T) {
 	tests := []marshalTest{
 		{
@@ -981,6 +1281,12 @@ func TestEncodeRequestBody(t *testing.T) {
 			encoding:    ContentEncoding("compress"),
 			expectError: false,
 		},
+		{
+			name:        "Identity non-empty body",
+			body:        []byte("unchanged data"),
+			encoding:    ContentEncoding("identity"),
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1007,7 +1313,7 @@ func TestEncodeRequestBody(t *testing.T) {
 					t.Fatalf("Test could not complete! (%s)", err.Error())
 				}
 			case ContentEncodingCompress, ContentEncodingXCompress:
-				reader := lzw.NewReader(reader, lzw.LSB, 8)
+				reader := lzw.NewReader(reader, compressOrder, compressLitWidth)
 				defer reader.Close()
 
 				decoded, err = io.ReadAll(reader)
@@ -1022,3 +1328,73 @@ func TestEncodeRequestBody(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeRequestBody_roundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding ContentEncoding
+	}{
+		{
+			name:     "Unencoded body",
+			body:     []byte("Hello, World!"),
+			encoding: ContentEncoding(""),
+		},
+		{
+			name:     "Deflate body",
+			body:     []byte("The quick brown fox jumps over the lazy dog"),
+			encoding: ContentEncoding("deflate"),
+		},
+		{
+			name:     "Deflate empty body",
+			body:     []byte(""),
+			encoding: ContentEncoding("deflate"),
+		},
+		{
+			name:     "Identity body",
+			body:     []byte("Hello, World!"),
+			encoding: ContentEncoding("identity"),
+		},
+		{
+			name:     "Gzip body",
+			body:     []byte("The quick brown fox jumps over the lazy dog"),
+			encoding: ContentEncoding("gzip"),
+		},
+		{
+			name:     "X-Gzip body",
+			body:     []byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+			encoding: ContentEncoding("x-gzip"),
+		},
+		{
+			name:     "Compress body",
+			body:     []byte("Test123!@# $%^&*()"),
+			encoding: ContentEncoding("compress"),
+		},
+		{
+			name:     "X-Compress body",
+			body:     []byte("aaaaaabbbbbbccccccaaaaaabbbbbbcccccc"),
+			encoding: ContentEncoding("x-compress"),
+		},
+		{
+			name:     "X-Compress empty body",
+			body:     []byte(""),
+			encoding: ContentEncoding("x-compress"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeRequestBody(tt.body, tt.encoding)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			decoded, err := decodeRequestBody(encoded, 0, tt.encoding)
+			if err != nil {
+				t.Fatalf("could not decode own encoding: %s", err.Error())
+			}
+
+			assert.SliceEqual(t, decoded, tt.body)
+		})
+	}
+}
@@ -2,20 +2,22 @@ package http
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
 type Method string
 
 const (
-	MethodGet  Method = "GET"
-	MethodHead Method = "HEAD"
-	MethodPost Method = "POST"
+	MethodGet     Method = "GET"
+	MethodHead    Method = "HEAD"
+	MethodPost    Method = "POST"
+	MethodOptions Method = "OPTIONS"
 )
 
 func (m Method) Validate() error {
 	switch m {
-	case MethodGet, MethodHead, MethodPost:
+	case MethodGet, MethodHead, MethodPost, MethodOptions:
 		return nil
 	}
 	return fmt.Errorf("invalid method")
@@ -28,18 +30,51 @@ const (
 	ContentEncodingGZip      = "gzip"
 	ContentEncodingXCompress = "x-compress"
 	ContentEncodingCompress  = "compress"
+	// ContentEncodingDeflate is the zlib format (RFC 1950) wrapping a deflate stream (RFC 1951).
+	ContentEncodingDeflate = "deflate"
+	// ContentEncodingIdentity is the coding that applies no transformation.
+	ContentEncodingIdentity = "identity"
 )
 
 func (e ContentEncoding) Validate() error {
 	switch e {
-	case ContentEncodingXGzip, ContentEncodingGZip, ContentEncodingXCompress, ContentEncodingCompress:
+	case ContentEncodingXGzip, ContentEncodingGZip, ContentEncodingXCompress, ContentEncodingCompress, ContentEncodingDeflate, ContentEncodingIdentity:
 		return nil
 	}
 	return fmt.Errorf("unknown encoding")
 }
 
+type AcceptedEncoding struct {
+	Encoding ContentEncoding
+	Quality  float64
+}
+
+// AcceptedValue is a charset or language range from an Accept-Charset or Accept-Language header, with its q value.
+type AcceptedValue struct {
+	Value   string
+	Quality float64
+}
+
 type ContentLength uint64
 
+type ConnectionOptions []string
+
+func (co ConnectionOptions) Has(option string) bool {
+	for _, o := range co {
+		if strings.EqualFold(o, option) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MimeVersion is the MIME-Version of a message. The zero value means the header was not sent.
+type MimeVersion struct {
+	Major int
+	Minor int
+}
+
 type MessageTime struct {
 	date time.Time
 }
@@ -49,6 +84,20 @@ type PragmaDirectives struct {
 	Options map[string]string
 }
 
+// ByteRange is a single range from a Range header. Unit is empty when the request had no usable Range header. Start is
+// -1 for a suffix range (the last SuffixLength bytes), and End is -1 when the range runs to the end of the entity.
+type ByteRange struct {
+	Unit         string
+	Start        int64
+	End          int64
+	SuffixLength int64
+}
+
+type CacheControl struct {
+	Flags   map[string]bool
+	Options map[string]string
+}
+
 type ContentType struct {
 	Type       string
 	Subtype    string
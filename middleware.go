@@ -0,0 +1,69 @@
+package http
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Chain returns h wrapped in middlewares. The first middleware is outermost, so it runs first, and the last wraps
+// closest to h.
+func Chain(h Handler, middlewares ...func(Handler) Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+// WithAccessLog returns a handler that serves each request with next, then logs its method, target, status code,
+// response body size and duration to logger.
+func WithAccessLog(logger *slog.Logger, next Handler) Handler {
+	return HandlerFunc(func(r Request, w *ResponseWriter) {
+		start := time.Now()
+		next.ServeHTTP(r, w)
+
+		logger.Info("request",
+			slog.String("method", string(r.Line.Method)),
+			slog.String("target", requestTarget(r)),
+			slog.Int("status", w.Status()),
+			slog.Int("bytes", w.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// Recoverer returns a handler that serves each request with next, recovering from any panic it raises. The panic is
+// logged to logger along with its stack trace, and, unless next already wrote a body, the client receives a 500 Internal
+// Server Error.
+func Recoverer(logger *slog.Logger, next Handler) Handler {
+	return HandlerFunc(func(r Request, w *ResponseWriter) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			logger.Error("handler panicked",
+				slog.Any("panic", v),
+				slog.String("method", string(r.Line.Method)),
+				slog.String("target", requestTarget(r)),
+				slog.String("stack", string(debug.Stack())),
+			)
+
+			if w.stream == nil && len(w.response.body) == 0 {
+				w.Error(StatusInternalServerError, StatusText(StatusInternalServerError))
+			}
+		}()
+
+		next.ServeHTTP(r, w)
+	})
+}
+
+func requestTarget(r Request) string {
+	if r.Line.AbsoluteUri != nil {
+		return string(r.Line.AbsoluteUri.marshal())
+	}
+
+	return string(r.Line.Uri.marshal())
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tony-montemuro/http/internal/assert"
+)
+
+func TestChain(t *testing.T) {
+	var trace []string
+	trail := func(name string) func(Handler) Handler {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(r Request, w *ResponseWriter) {
+				trace = append(trace, name+" before")
+				next.ServeHTTP(r, w)
+				trace = append(trace, name+" after")
+			})
+		}
+	}
+	h := HandlerFunc(func(r Request, w *ResponseWriter) {
+		trace = append(trace, "handler")
+	})
+
+	tests := []struct {
+		name        string
+		middlewares []func(Handler) Handler
+		expected    []string
+	}{
+		{"None", nil, []string{"handler"}},
+		{"One", []func(Handler) Handler{trail("auth")}, []string{"auth before", "handler", "auth after"}},
+		{"Three", []func(Handler) Handler{trail("recover"), trail("log"), trail("auth")}, []string{"recover before", "log before", "auth before", "handler", "auth after", "log after", "recover after"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace = nil
+			w := ResponseWriter{response: getDefaultResponse()}
+			Chain(h, tt.middlewares...).ServeHTTP(Request{}, &w)
+
+			assert.SliceEqual(t, trace, tt.expected)
+		})
+	}
+}
+
+func TestWithAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	h := WithAccessLog(logger, HandlerFunc(func(r Request, w *ResponseWriter) {
+		w.SetBody([]byte("hello"))
+	}))
+
+	r := Request{Line: RequestLine{Method: MethodGet, Uri: RelativeUri{Path: []byte("/greet"), Query: []byte("name=tony")}}}
+	w := ResponseWriter{response: getDefaultResponse()}
+	h.ServeHTTP(r, &w)
+
+	var entry map[string]any
+	err := json.Unmarshal(logs.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	assert.Equal(t, entry["msg"], any("request"))
+	assert.Equal(t, entry["method"], any("GET"))
+	assert.Equal(t, entry["target"], any("/greet?name=tony"))
+	assert.Equal(t, entry["status"], any(float64(StatusOK)))
+	assert.Equal(t, entry["bytes"], any(float64(5)))
+
+	_, ok := entry["duration"]
+	assert.Equal(t, ok, true)
+}
+
+func TestRecoverer(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      HandlerFunc
+		expectedCode code
+		expectedBody string
+	}{
+		{
+			name:         "Panic before writing",
+			handler:      func(r Request, w *ResponseWriter) { panic("boom") },
+			expectedCode: StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+		{
+			name: "Panic after writing",
+			handler: func(r Request, w *ResponseWriter) {
+				w.SetStatus(StatusAccepted)
+				w.SetBody([]byte("partial"))
+				panic("boom")
+			},
+			expectedCode: StatusAccepted,
+			expectedBody: "partial",
+		},
+		{
+			name:         "No panic",
+			handler:      func(r Request, w *ResponseWriter) { w.SetBody([]byte("ok")) },
+			expectedCode: StatusOK,
+			expectedBody: "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			h := Recoverer(slog.New(slog.NewTextHandler(&logs, nil)), tt.handler)
+
+			r := Request{Line: RequestLine{Method: MethodGet, Uri: RelativeUri{Path: []byte("/")}}}
+			w := ResponseWriter{response: getDefaultResponse()}
+			h.ServeHTTP(r, &w)
+
+			assert.Equal(t, w.response.code, tt.expectedCode)
+			assert.Equal(t, string(w.response.body), tt.expectedBody)
+			assert.Equal(t, strings.Contains(logs.String(), "handler panicked"), tt.expectedCode != StatusOK)
+		})
+	}
+}
+
+func TestServer_handleRecoveredPanic(t *testing.T) {
+	s := Server{
+		Handler: Recoverer(slog.New(slog.DiscardHandler), HandlerFunc(func(r Request, w *ResponseWriter) {
+			if string(r.Line.Uri.Path) == "/panic" {
+				panic("boom")
+			}
+			w.SetBody([]byte("still up"))
+		})),
+		ErrorLog: slog.New(slog.DiscardHandler),
+	}
+	s.init()
+
+	for _, path := range []string{"/panic", "/next"} {
+		server, client := net.Pipe()
+		go s.handle(server)
+
+		go func() {
+			client.Write([]byte("GET " + path + " HTTP/1.0\r\n\r\n"))
+		}()
+
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		res, err := readTestResponse(t, bufio.NewReader(client))
+		client.Close()
+		if err != nil {
+			t.Fatalf("could not read response for %s: %s", path, err.Error())
+		}
+
+		if path == "/panic" {
+			assert.Equal(t, res.line, "HTTP/1.0 500 Internal Server Error")
+			assert.Equal(t, res.body, "Internal Server Error\n")
+		} else {
+			assert.Equal(t, res.line, "HTTP/1.0 200 OK")
+			assert.Equal(t, res.body, "still up")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tony-montemuro/http/internal/constructs"
+)
+
+type route struct {
+	method   Method
+	segments []string
+	handler  Handler
+}
+
+// ServeMux routes requests to handlers by method and path. Patterns are absolute paths whose segments are either
+// matched literally, or, when written as :name, match any single segment and capture it for Request.PathParam.
+type ServeMux struct {
+	routes []route
+}
+
+func (mux *ServeMux) Handle(method, pattern string, h Handler) error {
+	err := constructs.ValidateToken(method)
+	if err != nil {
+		return fmt.Errorf("invalid method: %s", err.Error())
+	}
+
+	if h == nil {
+		return fmt.Errorf("handler cannot be nil")
+	}
+
+	segments, err := parseRoutePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range mux.routes {
+		if r.method == Method(method) && slices.Equal(r.segments, segments) {
+			return fmt.Errorf("route already registered (%s %s)", method, pattern)
+		}
+	}
+
+	mux.routes = append(mux.routes, route{method: Method(method), segments: segments, handler: h})
+	return nil
+}
+
+func parseRoutePattern(pattern string) ([]string, error) {
+	if len(pattern) == 0 || pattern[0] != constructs.ByteSeparator {
+		return nil, fmt.Errorf("pattern must be an absolute path (%s)", pattern)
+	}
+
+	segments := strings.Split(pattern[1:], string(constructs.ByteSeparator))
+	names := make(map[string]bool)
+
+	for _, segment := range segments {
+		name, isWildcard := strings.CutPrefix(segment, ":")
+		if !isWildcard {
+			continue
+		}
+
+		if len(name) == 0 {
+			return nil, fmt.Errorf("wildcard must be named (%s)", pattern)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("wildcard name used more than once (%s)", pattern)
+		}
+		names[name] = true
+	}
+
+	return segments, nil
+}
+
+// Handler returns the handler registered for the request's method and path. When no route matches the path, the
+// returned handler responds with 404 Not Found; when routes match the path but not the method, it responds with 405
+// Method Not Allowed and an Allow header listing the registered methods.
+func (mux *ServeMux) Handler(r Request) Handler {
+	path := string(r.Line.Uri.Path)
+	segments := strings.Split(strings.TrimPrefix(path, string(constructs.ByteSeparator)), string(constructs.ByteSeparator))
+
+	var match *route
+	var params map[string]string
+	var allowed []Method
+	best := -1
+
+	for i, rt := range mux.routes {
+		captured, statics, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+
+		if rt.method != r.Line.Method {
+			if !slices.Contains(allowed, rt.method) {
+				allowed = append(allowed, rt.method)
+			}
+			continue
+		}
+
+		if statics > best {
+			match = &mux.routes[i]
+			params = captured
+			best = statics
+		}
+	}
+
+	if match != nil {
+		h := match.handler
+		return HandlerFunc(func(r Request, w *ResponseWriter) {
+			r.pathParams = params
+			h.ServeHTTP(r, w)
+		})
+	}
+
+	if len(allowed) > 0 {
+		return MethodNotAllowedHandler(allowed...)
+	}
+
+	return NotFoundHandler()
+}
+
+// Methods returns every method with a registered route, in the order they were first registered.
+func (mux *ServeMux) Methods() []Method {
+	var methods []Method
+
+	for _, r := range mux.routes {
+		if !slices.Contains(methods, r.method) {
+			methods = append(methods, r.method)
+		}
+	}
+
+	return methods
+}
+
+func (mux *ServeMux) ServeHTTP(r Request, w *ResponseWriter) {
+	mux.Handler(r).ServeHTTP(r, w)
+}
+
+func (rt route) match(segments []string) (map[string]string, int, bool) {
+	if len(rt.segments) != len(segments) {
+		return nil, 0, false
+	}
+
+	params := make(map[string]string)
+	statics := 0
+
+	for i, segment := range rt.segments {
+		name, isWildcard := strings.CutPrefix(segment, ":")
+
+		if isWildcard && len(segments[i]) > 0 {
+			params[name] = segments[i]
+		} else if segment == segments[i] {
+			statics++
+		} else {
+			return nil, 0, false
+		}
+	}
+
+	return params, statics, true
+}
+
+// NotFoundHandler returns a handler that responds to every request with 404 Not Found.
+func NotFoundHandler() Handler {
+	return HandlerFunc(notFound)
+}
+
+func notFound(r Request, w *ResponseWriter) {
+	w.SetStatus(StatusNotFound)
+	w.SetBody([]byte(StatusText(StatusNotFound)))
+}
+
+// MethodNotAllowedHandler returns a handler that responds to every request with 405 Method Not Allowed, and an Allow
+// header listing allowed.
+func MethodNotAllowedHandler(allowed ...Method) Handler {
+	return HandlerFunc(func(r Request, w *ResponseWriter) {
+		w.MethodNotAllowed(allowed...)
+		w.SetBody([]byte(StatusText(StatusMethodNotAllowed)))
+	})
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/tony-montemuro/http/internal/assert"
+)
+
+func TestParseRoutePattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:        "Root",
+			pattern:     "/",
+			expected:    []string{""},
+			expectError: false,
+		},
+		{
+			name:        "Static segments",
+			pattern:     "/users/all",
+			expected:    []string{"users", "all"},
+			expectError: false,
+		},
+		{
+			name:        "Wildcard segments",
+			pattern:     "/users/:id/posts/:post",
+			expected:    []string{"users", ":id", "posts", ":post"},
+			expectError: false,
+		},
+		{
+			name:        "Relative pattern",
+			pattern:     "users",
+			expectError: true,
+		},
+		{
+			name:        "Unnamed wildcard",
+			pattern:     "/users/:",
+			expectError: true,
+		},
+		{
+			name:        "Duplicate wildcard name",
+			pattern:     "/:id/:id",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseRoutePattern(tt.pattern)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.SliceEqual(t, res, tt.expected)
+		})
+	}
+}
+
+func TestServeMux_Handle(t *testing.T) {
+	h := HandlerFunc(func(r Request, w *ResponseWriter) {})
+	mux := ServeMux{}
+
+	err := mux.Handle("GET", "/users/:id", h)
+	assert.ErrorStatus(t, err, false)
+
+	err = mux.Handle("POST", "/users/:id", h)
+	assert.ErrorStatus(t, err, false)
+
+	err = mux.Handle("GET", "/users/:id", h)
+	assert.ErrorStatus(t, err, true)
+
+	err = mux.Handle("G ET", "/users", h)
+	assert.ErrorStatus(t, err, true)
+
+	err = mux.Handle("GET", "/users", nil)
+	assert.ErrorStatus(t, err, true)
+}
+
+func TestServeMux_ServeHTTP(t *testing.T) {
+	mux := ServeMux{}
+	routes := []struct {
+		method  string
+		pattern string
+		body    func(r Request) string
+	}{
+		{"GET", "/", func(r Request) string { return "index" }},
+		{"GET", "/users/me", func(r Request) string { return "me" }},
+		{"GET", "/users/:id", func(r Request) string { return "user " + r.PathParam("id") }},
+		{"POST", "/users/:id", func(r Request) string { return "update " + r.PathParam("id") }},
+		{"GET", "/users/:id/posts/:post", func(r Request) string { return r.PathParam("id") + ":" + r.PathParam("post") }},
+	}
+
+	for _, rt := range routes {
+		body := rt.body
+		err := mux.Handle(rt.method, rt.pattern, HandlerFunc(func(r Request, w *ResponseWriter) {
+			w.SetBody([]byte(body(r)))
+		}))
+		if err != nil {
+			t.Fatalf("Test could not complete! (%s)", err.Error())
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method Method
+		path   string
+		code   code
+		body   string
+		allow  []Method
+	}{
+		{
+			name:   "Root",
+			method: MethodGet,
+			path:   "/",
+			code:   StatusOK,
+			body:   "index",
+		},
+		{
+			name:   "Static route preferred over wildcard",
+			method: MethodGet,
+			path:   "/users/me",
+			code:   StatusOK,
+			body:   "me",
+		},
+		{
+			name:   "Wildcard route",
+			method: MethodGet,
+			path:   "/users/42",
+			code:   StatusOK,
+			body:   "user 42",
+		},
+		{
+			name:   "Wildcard route with other method",
+			method: MethodPost,
+			path:   "/users/42",
+			code:   StatusOK,
+			body:   "update 42",
+		},
+		{
+			name:   "Multiple wildcards",
+			method: MethodGet,
+			path:   "/users/7/posts/hello",
+			code:   StatusOK,
+			body:   "7:hello",
+		},
+		{
+			name:   "Empty wildcard segment",
+			method: MethodGet,
+			path:   "/users/",
+			code:   StatusNotFound,
+			body:   "Not Found",
+		},
+		{
+			name:   "Unknown path",
+			method: MethodGet,
+			path:   "/posts",
+			code:   StatusNotFound,
+			body:   "Not Found",
+		},
+		{
+			name:   "Method not allowed",
+			method: Method("PUT"),
+			path:   "/users/42",
+			code:   StatusMethodNotAllowed,
+			body:   "Method Not Allowed",
+			allow:  []Method{MethodGet, MethodPost},
+		},
+		{
+			name:   "Method not allowed on wildcard route",
+			method: MethodHead,
+			path:   "/users/7/posts/hello",
+			code:   StatusMethodNotAllowed,
+			body:   "Method Not Allowed",
+			allow:  []Method{MethodGet},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Line: RequestLine{Method: tt.method, Uri: RelativeUri{Path: []byte(tt.path)}}}
+			w := ResponseWriter{response: getDefaultResponse()}
+
+			mux.ServeHTTP(r, &w)
+
+			assert.Equal(t, w.response.code, tt.code)
+			assert.Equal(t, string(w.response.body), tt.body)
+			assert.SliceEqual(t, w.response.headers.allow.methods, tt.allow)
+		})
+	}
+}
+
+func TestDefaultHandlers(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		code    code
+		body    string
+		allow   []Method
+	}{
+		{
+			name:    "Not found",
+			handler: NotFoundHandler(),
+			code:    StatusNotFound,
+			body:    "Not Found",
+		},
+		{
+			name:    "Method not allowed",
+			handler: MethodNotAllowedHandler(MethodHead, MethodGet, MethodHead),
+			code:    StatusMethodNotAllowed,
+			body:    "Method Not Allowed",
+			allow:   []Method{MethodGet, MethodHead},
+		},
+		{
+			name:    "Method not allowed without methods",
+			handler: MethodNotAllowedHandler(),
+			code:    StatusMethodNotAllowed,
+			body:    "Method Not Allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Line: RequestLine{Method: MethodPost, Uri: RelativeUri{Path: []byte("/")}}}
+			w := ResponseWriter{response: getDefaultResponse()}
+
+			tt.handler.ServeHTTP(r, &w)
+
+			assert.Equal(t, w.response.code, tt.code)
+			assert.Equal(t, string(w.response.body), tt.body)
+			assert.SliceEqual(t, w.response.headers.allow.methods, tt.allow)
+		})
+	}
+}
@@ -5,13 +5,22 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/lzw"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/mail"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tony-montemuro/http/internal/constructs"
@@ -19,83 +28,446 @@ import (
 	"github.com/tony-montemuro/http/internal/rules"
 )
 
-func parseRequest(conn net.Conn, server Server) (*Request, error) {
-	conn.SetReadDeadline(time.Now().Add(time.Duration(server.ReadTimeout) * time.Millisecond))
-	defer conn.SetReadDeadline(time.Time{})
+// ParseOptions holds the limits a request is parsed under. They mirror the Server fields of the same name, and a zero
+// limit takes the same default a Server would. HeaderTimeout and BodyTimeout only apply when the reader has a
+// SetReadDeadline method, such as a net.Conn; a zero timeout sets no deadline.
+type ParseOptions struct {
+	MaxHeaderBytes       uint16
+	MaxHeaderCount       uint16
+	MaxURILength         uint16
+	MaxRequestLineBytes  uint16
+	MaxBodyBytes         uint64
+	MaxDecompressedBytes uint64
+	StreamBodyThreshold  uint64
+	AllowedMethods       []Method
+	AllowAbsoluteURI     bool
+	RejectObsFold        bool
+	MinHeaderReadRate    uint16
+	HeaderTimeout        time.Duration
+	BodyTimeout          time.Duration
+}
+
+func (o ParseOptions) withDefaults() ParseOptions {
+	if o.MaxHeaderBytes == 0 {
+		o.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if o.MaxHeaderCount == 0 {
+		o.MaxHeaderCount = defaultMaxHeaderCount
+	}
+	if o.MaxURILength == 0 {
+		o.MaxURILength = defaultMaxURILength
+	}
+	if o.MaxRequestLineBytes == 0 {
+		o.MaxRequestLineBytes = defaultMaxRequestLineBytes(o.MaxURILength)
+	}
+	if o.MaxBodyBytes == 0 {
+		o.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if o.MaxDecompressedBytes == 0 {
+		o.MaxDecompressedBytes = defaultDecompressionRatio * o.MaxBodyBytes
+	}
+
+	return o
+}
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type remoteAddresser interface {
+	RemoteAddr() net.Addr
+}
+
+type requestReader struct {
+	deadliner readDeadliner
+	addresser remoteAddresser
+	isTLS     bool
+	rate      *rateReader
+	limited   *io.LimitedReader
+	reader    *bufio.Reader
+	// pipelined is set once a request has been read, after which empty lines before a request line are ignored
+	pipelined bool
+}
+
+func newRequestReader(r io.Reader) *requestReader {
+	rate := &rateReader{r: r}
+	limited := &io.LimitedReader{R: rate}
+	deadliner, _ := r.(readDeadliner)
+	addresser, _ := r.(remoteAddresser)
+	_, isTLS := r.(*tls.Conn)
+	return &requestReader{deadliner: deadliner, addresser: addresser, isTLS: isTLS, rate: rate, limited: limited, reader: bufio.NewReader(limited)}
+}
+
+// minReadRateGrace is how long a client may send slowly before its read rate is held to the minimum, so a request split
+// across a few packets is not mistaken for a client dribbling bytes.
+const minReadRateGrace = time.Second
+
+// rateReader fails with errSlowHeaders once bytes arrive slower than min bytes per second. The clock starts at the first
+// byte, so time spent idle between requests is not counted. A min of 0 disables the check.
+type rateReader struct {
+	r     io.Reader
+	min   uint16
+	start time.Time
+	read  int
+}
+
+func (rr *rateReader) reset(min uint16) {
+	rr.min = min
+	rr.start = time.Time{}
+	rr.read = 0
+}
+
+func (rr *rateReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if rr.min == 0 || n == 0 {
+		return n, err
+	}
+
+	if rr.start.IsZero() {
+		rr.start = time.Now()
+	}
+	rr.read += n
+
+	elapsed := time.Since(rr.start)
+	if elapsed >= minReadRateGrace && float64(rr.read)/elapsed.Seconds() < float64(rr.min) {
+		return n, errSlowHeaders
+	}
+
+	return n, err
+}
+
+// ParseRequest reads a single request from r, such as a buffered or recorded request, under the limits in opts. A body
+// larger than opts.StreamBodyThreshold is left in r, to be read through Request.BodyReader.
+func ParseRequest(r io.Reader, opts ParseOptions) (*Request, error) {
+	return newRequestReader(r).next(opts.withDefaults())
+}
 
-	limitedReader := &io.LimitedReader{
-		R: conn,
-		N: int64(server.MaxHeaderBytes),
+// setReadDeadline sets a deadline timeout from now, if the underlying reader supports them. A timeout of 0 clears it.
+func (rr *requestReader) setReadDeadline(timeout time.Duration) {
+	if rr.deadliner == nil {
+		return
 	}
-	reader := bufio.NewReader(limitedReader)
-	lineBuf, err := reader.ReadBytes('\n')
+
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	rr.deadliner.SetReadDeadline(deadline)
+}
+
+// watchClose calls cancel if the peer closes the connection before the returned stop function is called. It waits for
+// the next byte in the background, so it must not be used while anything else reads from rr, such as a streamed body.
+// Any byte that arrives is kept for the next request.
+func (rr *requestReader) watchClose(cancel context.CancelFunc) (stop func()) {
+	// without deadlines, there is no way to interrupt the read once the handler is done
+	if rr.deadliner == nil {
+		return func() {}
+	}
+
+	rr.limited.N = 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := rr.reader.Peek(1)
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			cancel()
+		}
+	}()
+
+	return func() {
+		rr.deadliner.SetReadDeadline(time.Now())
+		<-done
+		rr.deadliner.SetReadDeadline(time.Time{})
+	}
+}
+
+func (rr *requestReader) next(opts ParseOptions) (*Request, error) {
+	r, err := rr.readRequest(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if !bytes.HasSuffix(lineBuf, []byte(constructs.Crlf)) {
-		return nil, ClientError{message: "malformed header suffix"}
+	if rr.addresser != nil {
+		r.RemoteAddr = rr.addresser.RemoteAddr()
+	}
+	r.isTLS = rr.isTLS
+	rr.pipelined = true
+	return r, nil
+}
+
+func (rr *requestReader) readRequest(opts ParseOptions) (*Request, error) {
+	rr.setReadDeadline(opts.HeaderTimeout)
+	defer rr.setReadDeadline(0)
+	rr.rate.reset(opts.MinHeaderReadRate)
+
+	// the request line gets its own budget, so a single long line is refused before it uses up the headers'
+	lineLimit := int64(opts.MaxHeaderBytes)
+	if opts.MaxRequestLineBytes > 0 {
+		lineLimit = min(lineLimit, int64(opts.MaxRequestLineBytes)+int64(len(constructs.Crlf)))
+	}
+	rr.limited.N = lineLimit
+	lineBuf, err := rr.reader.ReadBytes('\n')
+	// some clients send a CRLF after a POST body, so empty lines between requests are ignored
+	for err == nil && rr.pipelined && bytes.Equal(lineBuf, []byte(constructs.Crlf)) {
+		lineBuf, err = rr.reader.ReadBytes('\n')
+	}
+	if err != nil {
+		if len(lineBuf) == 0 && isIdleConnError(err) {
+			return nil, fmt.Errorf("%w: %w", errNoRequest, err)
+		}
+		if rr.limited.N <= 0 && lineLimit < int64(opts.MaxHeaderBytes) {
+			return nil, requestLineTooLongError(opts.MaxRequestLineBytes)
+		}
+		return nil, rr.headerReadError(err)
+	}
+	rr.limited.N = max(int64(opts.MaxHeaderBytes)-int64(len(lineBuf))-int64(rr.reader.Buffered()), 0)
+
+	// a bare LF is not accepted as a line terminator anywhere in the request head
+	lineBuf, ok := bytes.CutSuffix(lineBuf, []byte(constructs.Crlf))
+	if !ok {
+		return nil, ClientError{message: fmt.Sprintf("request line is not terminated by CRLF (%q)", lineBuf)}
+	}
+
+	if opts.MaxRequestLineBytes > 0 && len(lineBuf) > int(opts.MaxRequestLineBytes) {
+		return nil, requestLineTooLongError(opts.MaxRequestLineBytes)
 	}
 
-	line, err := parseRequestLine(bytes.Trim(lineBuf, constructs.Crlf))
+	line, err := parseRequestLine(lineBuf, opts.AllowAbsoluteURI, opts.MaxURILength, opts.AllowedMethods)
 	if err != nil {
 		return nil, err
 	}
 
 	var headerBuf bytes.Buffer
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := rr.reader.ReadString('\n')
 		if err != nil {
-			return nil, err
+			return nil, rr.headerReadError(err)
+		}
+		if !strings.HasSuffix(line, constructs.Crlf) {
+			return nil, ClientError{message: fmt.Sprintf("header line is not terminated by CRLF (%q)", line)}
 		}
-		if line == "\r\n" {
+		if line == constructs.Crlf {
 			break
 		}
 
 		headerBuf.WriteString(line)
 	}
+	rr.rate.reset(0)
 
-	headers, err := parseRequestHeaders(bytes.Trim(headerBuf.Bytes(), constructs.Crlf))
+	headers, err := parseRequestHeaders(bytes.Trim(headerBuf.Bytes(), constructs.Crlf), opts.MaxHeaderCount, opts.RejectObsFold)
 	if err != nil {
 		return nil, err
 	}
-	if headers.ContentLength > ContentLength(server.MaxBodyBytes) {
-		return nil, ClientError{message: fmt.Sprintf("Content-Length exceeds max allowed by server: %d", server.MaxBodyBytes)}
+
+	rr.setReadDeadline(opts.BodyTimeout)
+	if headers.IsChunked() {
+		// chunk framing is not counted towards MaxBodyBytes, so allow for it on top of the body itself
+		rr.limited.N = int64(opts.MaxBodyBytes) + int64(opts.MaxHeaderBytes)
+		bodyBytes, err := rr.readChunkedBody(uint64(opts.MaxBodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		headers.ContentLength = ContentLength(len(bodyBytes))
+
+		raw, body, err := parseRequestBody(bodyBytes, headers, opts.MaxDecompressedBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Request{Line: line, Headers: headers, Body: body, RawBody: raw}, nil
+	}
+
+	rr.limited.N = int64(headers.ContentLength)
+	if opts.StreamBodyThreshold > 0 && uint64(headers.ContentLength) > opts.StreamBodyThreshold {
+		body := &bodyReader{
+			rr:        rr,
+			timeout:   opts.BodyTimeout,
+			remaining: int64(headers.ContentLength),
+			allowed:   int64(opts.MaxBodyBytes),
+		}
+		return &Request{Line: line, Headers: headers, body: body}, nil
+	}
+
+	if headers.ContentLength > ContentLength(opts.MaxBodyBytes) {
+		return nil, ClientError{message: fmt.Sprintf("Content-Length exceeds max allowed by server: %d", opts.MaxBodyBytes), status: StatusRequestEntityTooLarge}
 	}
 
 	bodyBytes := make([]byte, headers.ContentLength)
-	_, err = io.ReadFull(reader, bodyBytes)
+	_, err = io.ReadFull(rr.reader, bodyBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := parseRequestBody(bodyBytes, headers)
+	raw, body, err := parseRequestBody(bodyBytes, headers, opts.MaxDecompressedBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Request{Line: line, Headers: headers, Body: body}, nil
+	return &Request{Line: line, Headers: headers, Body: body, RawBody: raw}, nil
+}
+
+// readChunkedBody reads a body sent with the chunked transfer coding, returning it with the chunk framing removed. Chunk
+// extensions and trailers are ignored. A body that decodes to more than max bytes is refused.
+func (rr *requestReader) readChunkedBody(max uint64) ([]byte, error) {
+	var body []byte
+
+	for {
+		line, err := rr.readChunkLine()
+		if err != nil {
+			return nil, err
+		}
+
+		sizeField, _, _ := strings.Cut(line, ";")
+		sizeField = lws.Trim(sizeField)
+		size, err := strconv.ParseUint(sizeField, 16, 64)
+		if err != nil {
+			return nil, ClientError{message: fmt.Sprintf("malformed chunk size (%s)", line)}
+		}
+
+		if size == 0 {
+			break
+		}
+		if size > max-uint64(len(body)) {
+			return nil, ClientError{message: "body exceeds max allowed by server", status: StatusRequestEntityTooLarge}
+		}
+
+		chunk := make([]byte, size+uint64(len(constructs.Crlf)))
+		_, err = io.ReadFull(rr.reader, chunk)
+		if err != nil {
+			return nil, rr.chunkReadError(err)
+		}
+		if !bytes.HasSuffix(chunk, []byte(constructs.Crlf)) {
+			return nil, ClientError{message: "chunk data is not terminated by CRLF"}
+		}
+
+		body = append(body, chunk[:size]...)
+	}
+
+	for {
+		line, err := rr.readChunkLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			return body, nil
+		}
+	}
+}
+
+// readChunkLine reads a chunk-size or trailer line, without its CRLF.
+func (rr *requestReader) readChunkLine() (string, error) {
+	line, err := rr.reader.ReadString('\n')
+	if err != nil {
+		return "", rr.chunkReadError(err)
+	}
+
+	line, ok := strings.CutSuffix(line, constructs.Crlf)
+	if !ok {
+		return "", ClientError{message: fmt.Sprintf("chunk line is not terminated by CRLF (%s)", line)}
+	}
+
+	return line, nil
+}
+
+func (rr *requestReader) chunkReadError(err error) error {
+	if rr.limited.N <= 0 {
+		return ClientError{message: "body exceeds max allowed by server", status: StatusRequestEntityTooLarge}
+	}
+
+	return err
+}
+
+// bodyReader streams a request body straight from the connection, for bodies too large to be read up front. It yields
+// the body as it was sent, without removing any content codings.
+type bodyReader struct {
+	rr        *requestReader
+	timeout   time.Duration
+	remaining int64
+	allowed   int64
+}
+
+func (br *bodyReader) Read(p []byte) (int, error) {
+	if br.remaining == 0 {
+		return 0, io.EOF
+	}
+	if br.allowed <= 0 {
+		return 0, ClientError{message: "body exceeds max allowed by server", status: StatusRequestEntityTooLarge}
+	}
+
+	p = p[:min(int64(len(p)), br.remaining, br.allowed)]
+
+	br.rr.setReadDeadline(br.timeout)
+	defer br.rr.setReadDeadline(0)
+
+	n, err := br.rr.reader.Read(p)
+	br.remaining -= int64(n)
+	br.allowed -= int64(n)
+
+	if errors.Is(err, io.EOF) && br.remaining > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// discard reads whatever the handler left of the body, so the connection is positioned at the start of the next
+// request.
+func (br *bodyReader) discard() error {
+	_, err := io.Copy(io.Discard, br)
+	return err
+}
+
+func (rr *requestReader) headerReadError(err error) error {
+	if errors.Is(err, errSlowHeaders) {
+		return ClientError{message: err.Error(), status: StatusRequestTimeout, err: err}
+	}
+	if rr.limited.N <= 0 {
+		return ClientError{message: "request headers exceed max allowed by server", status: StatusRequestEntityTooLarge}
+	}
+
+	return err
 }
 
-func parseRequestLine(data []byte) (RequestLine, error) {
+func requestLineTooLongError(max uint16) error {
+	return ClientError{message: fmt.Sprintf("Invalid request line: request line exceeds max length allowed by server: %d", max), status: StatusRequestURITooLong}
+}
+
+// parseRequestLine parses data into a RequestLine. The Request-URI must be an abs_path, unless allowAbsoluteUri is set,
+// in which case an absoluteURI is accepted as well, with its net_path stored in Uri. OPTIONS requests may also use "*",
+// which is stored as the path. A Request-URI longer than maxUriLength is refused, unless maxUriLength is 0. If
+// allowedMethods is not empty, only its methods are accepted.
+func parseRequestLine(data []byte, allowAbsoluteUri bool, maxUriLength uint16, allowedMethods []Method) (RequestLine, error) {
 	parts := bytes.Split(data, []byte(" "))
 	if len(parts) != 3 {
 		return RequestLine{}, ClientError{message: fmt.Sprintf("Invalid request line: malformed request line (%s)", data)}
 	}
 
-	m := Method(parts[0])
-	err := m.Validate()
-	if err != nil {
-		return RequestLine{}, ClientError{message: fmt.Sprintf("Invalid request line: issue with request method (%s)", err.Error())}
+	if maxUriLength > 0 && len(parts[1]) > int(maxUriLength) {
+		return RequestLine{}, ClientError{message: fmt.Sprintf("Invalid request line: uri exceeds max length allowed by server: %d", maxUriLength), status: StatusRequestURITooLong}
 	}
 
-	uri, err := parseRelativeUri(parts[1])
+	m := Method(parts[0])
+	err := validateRequestMethod(m, allowedMethods)
 	if err != nil {
 		return RequestLine{}, err
 	}
 
-	if uri.getPathForm() != AbsPath {
-		return RequestLine{}, fmt.Errorf("Invalid request line: issue with uri (uri must be in the form of an absolute path)")
+	var absoluteUri *AbsoluteUri
+	var uri RelativeUri
+
+	if m == MethodOptions && string(parts[1]) == "*" {
+		uri = RelativeUri{Path: parts[1]}
+	} else if allowAbsoluteUri && validateStartsWithScheme(parts[1]) == nil {
+		absoluteUri, uri, err = parseProxyUri(parts[1])
+	} else {
+		uri, err = parseRelativeUri(parts[1])
+		if err == nil && uri.getPathForm() != AbsPath {
+			err = ClientError{message: "Invalid request line: issue with uri (uri must be in the form of an absolute path)"}
+		}
+	}
+
+	if err != nil {
+		return RequestLine{}, err
 	}
 
 	version, err := parseVersion(string(parts[2]))
@@ -103,7 +475,53 @@ func parseRequestLine(data []byte) (RequestLine, error) {
 		return RequestLine{}, ClientError{message: fmt.Sprintf("Invalid request line: issue with version (%s)", version)}
 	}
 
-	return RequestLine{Method: m, Uri: uri, Version: version}, nil
+	return RequestLine{Method: m, Uri: uri, AbsoluteUri: absoluteUri, Version: version}, nil
+}
+
+// validateRequestMethod checks m against allowed, or against the methods this package implements when allowed is empty.
+// A well-formed method missing from allowed is refused with 501 Not Implemented.
+func validateRequestMethod(m Method, allowed []Method) error {
+	if len(allowed) == 0 {
+		err := m.Validate()
+		if err != nil {
+			return ClientError{message: fmt.Sprintf("Invalid request line: issue with request method (%s)", err.Error())}
+		}
+		return nil
+	}
+
+	err := constructs.ValidateToken(string(m))
+	if err != nil {
+		return ClientError{message: fmt.Sprintf("Invalid request line: issue with request method (%s)", err.Error())}
+	}
+
+	if !slices.Contains(allowed, m) {
+		return ClientError{message: fmt.Sprintf("Invalid request line: method not implemented by server (%s)", m), status: StatusNotImplemented}
+	}
+
+	return nil
+}
+
+// parseProxyUri parses an absoluteURI sent to a proxy, along with the net_path it names. An empty path is treated as "/".
+func parseProxyUri(data []byte) (*AbsoluteUri, RelativeUri, error) {
+	absoluteUri, err := parseAbsoluteUri(data)
+	if err != nil {
+		return nil, RelativeUri{}, ClientError{message: fmt.Sprintf("Invalid request line: issue with uri (%s)", err.Error())}
+	}
+
+	_, rest, _ := bytes.Cut(data, []byte{':'})
+	uri, err := parseRelativeUri(rest)
+	if err != nil {
+		return nil, RelativeUri{}, err
+	}
+
+	if uri.getPathForm() != NetPath || len(uri.NetLoc) == 0 {
+		return nil, RelativeUri{}, ClientError{message: "Invalid request line: issue with uri (absolute uri must name a host)"}
+	}
+	if len(uri.Path) == 0 {
+		uri.Path = []byte{constructs.ByteSeparator}
+	}
+
+	return &absoluteUri, uri, nil
 }
 
 func parseVersion(data string) (string, error) {
@@ -137,60 +555,81 @@ func parseVersion(data string) (string, error) {
 	return parts[1], nil
 }
 
-func parseRequestHeaders(data []byte) (RequestHeaders, error) {
-	headers := RequestHeaders{}
-	parts := splitRequestHeaders(data)
+var headerPartsPool = sync.Pool{
+	New: func() any {
+		parts := make([][]byte, 0, 16)
+		return &parts
+	},
+}
+
+// parseRequestHeaders parses data into RequestHeaders. A maxHeaderCount of 0 places no limit on the number of headers.
+// When rejectObsFold is set, header values continued onto a folded line are rejected.
+func parseRequestHeaders(data []byte, maxHeaderCount uint16, rejectObsFold bool) (RequestHeaders, error) {
+	headers := RequestHeaders{raw: make(map[string]string)}
+
+	scratch := headerPartsPool.Get().(*[][]byte)
+	parts := splitRequestHeaders(data, (*scratch)[:0])
+	defer func() {
+		clear(parts)
+		*scratch = parts[:0]
+		headerPartsPool.Put(scratch)
+	}()
+
+	if maxHeaderCount > 0 && len(parts) > int(maxHeaderCount) {
+		return headers, ClientError{message: fmt.Sprintf("too many headers (%d, max: %d)", len(parts), maxHeaderCount)}
+	}
 
 	for _, header := range parts {
-		parts := bytes.SplitN(header, []byte(":"), 2)
-		if len(parts) < 2 {
+		rawName, rawValue, ok := bytes.Cut(header, []byte(":"))
+		if !ok {
 			return headers, ClientError{message: fmt.Sprintf("Invalid header: cannot determine header name (%s)", header)}
 		}
 
-		name := lws.TrimRight(string(parts[0]))
+		name := lws.TrimRight(string(rawName))
 		err := validateHeaderName(name)
 		if err != nil {
 			return headers, ClientError{message: fmt.Sprintf("Invalid header: %s", err.Error())}
 		}
 
-		value := lws.TrimLeft(string(parts[1]))
+		value := lws.TrimLeft(string(rawValue))
+		if rejectObsFold && strings.Contains(value, constructs.Crlf) {
+			return headers, ClientError{message: fmt.Sprintf("Invalid header: %s value is folded across lines", name)}
+		}
+
 		err = validateHeaderValue(value)
 		if err != nil {
-			return headers, fmt.Errorf("Invalid header: (%s)", err.Error())
+			return headers, ClientError{message: fmt.Sprintf("Invalid header: (%s)", err.Error())}
 		}
 
 		err = headers.setHeader(name, value)
 		if err != nil {
-			return headers, ClientError{message: err.Error()}
+			return headers, ClientError{message: err.Error(), err: err}
 		}
 	}
 
 	return headers, nil
 }
 
-func splitRequestHeaders(data []byte) [][]byte {
-	parts := [][]byte{}
+// splitRequestHeaders appends each header in data, along with any continuation lines folded into it, to parts.
+func splitRequestHeaders(data []byte, parts [][]byte) [][]byte {
+	s := string(data)
 	start := 0
-	nextCrlf := bytes.Index(data, []byte(constructs.Crlf))
-	end := nextCrlf
 
-	for nextCrlf != -1 {
-		isLws, _ := lws.Check(string(data), end)
-		if !isLws {
-			parts = append(parts, data[start:end])
-			start = end + len(constructs.Crlf)
-			nextCrlf = bytes.Index(data[start:], []byte(constructs.Crlf))
-			end = start
-		} else {
-			nextCrlf = bytes.Index(data[end+len(constructs.Crlf):], []byte(constructs.Crlf))
-			end += len(constructs.Crlf)
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] != lws.CR || data[i+1] != lws.LF {
+			continue
 		}
 
-		end += nextCrlf
+		// a CRLF followed by LWS folds the next line into the current header
+		isLws, _ := lws.Check(s, i)
+		if !isLws {
+			parts = append(parts, data[start:i])
+			start = i + len(constructs.Crlf)
+		}
+		i++
 	}
 
-	last := data[start:]
-	if len(last) > 0 {
+	if start < len(data) {
 		parts = append(parts, data[start:])
 	}
 	return parts
@@ -222,42 +661,105 @@ func validateHeaderValue(data string) error {
 
 }
 
+// listHeaders are the headers whose value is a comma separated list. When one is sent more than once, the values are
+// combined into a single list, as if they had been sent in one field.
+var listHeaders = map[string]bool{
+	"Pragma":            true,
+	"Cache-Control":     true,
+	"Connection":        true,
+	"Via":               true,
+	"Allow":             true,
+	"Accept-Charset":    true,
+	"Accept-Encoding":   true,
+	"Accept-Language":   true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Link":              true,
+	"X-Forwarded-For":   true,
+}
+
+// setHeader parses value into the field for the header called name. Header names are matched case-insensitively, but
+// the name is stored in raw, and Unrecognized, as it was sent. Repeated list headers are combined, while any other
+// repeated header replaces the earlier value.
 func (rh *RequestHeaders) setHeader(name, value string) error {
 	var err error
+	canonical := canonicalHeaderName(name)
+
+	if listHeaders[canonical] {
+		key, previous, ok := rh.lookupRawHeader(canonical)
+		if ok {
+			name = key
+			value = previous + ", " + value
+		}
+	}
 
-	switch name {
+	switch canonical {
 	case "Date":
 		err = rh.setDate(value)
 	case "Pragma":
 		err = rh.setPragma(value)
+	case "Cache-Control":
+		err = rh.setCacheControl(value)
+	case "Connection":
+		err = rh.setConnection(value)
+	case "Mime-Version":
+		err = rh.setMimeVersion(value)
 	case "Authorization":
 		err = rh.setAuthorization(value)
 	case "Referer":
 		err = rh.setReferer(value)
+	case "Host":
+		err = rh.setHost(value)
+	case "Cookie":
+		err = rh.setCookie(value)
+	case "Range":
+		err = rh.setRange(value)
 	case "From":
 		err = rh.setFrom(value)
 	case "If-Modified-Since":
 		err = rh.setIfModifiedSince(value)
+	case "If-Unmodified-Since":
+		err = rh.setIfUnmodifiedSince(value)
 	case "User-Agent":
 		err = rh.setUserAgent(value)
+	case "Via":
+		err = rh.setVia(value)
 	case "Allow":
 		err = rh.setAllow(value)
+	case "Accept-Charset":
+		err = rh.setAcceptCharset(value)
+	case "Accept-Encoding":
+		err = rh.setAcceptEncoding(value)
+	case "Accept-Language":
+		err = rh.setAcceptLanguage(value)
 	case "Content-Encoding":
 		err = rh.setContentEncoding(value)
 	case "Content-Length":
 		err = rh.setContentLength(value)
+	case "Content-Md5":
+		err = rh.setContentMD5(value)
+	case "Transfer-Encoding":
+		err = rh.setTransferEncoding(value)
 	case "Expires":
 		err = rh.setExpires(value)
 	case "Last-Modified":
 		err = rh.setLastModified(value)
+	case "Link":
+		err = rh.setLink(value)
+	case "Title":
+		err = rh.setTitle(value)
 	case "Content-Type":
 		err = rh.setContentType(value)
+	case "X-Forwarded-For":
+		err = rh.setForwardedFor(value)
+	case "X-Forwarded-Proto":
+		err = rh.setForwardedProto(value)
 	default:
 		err = rh.setUnrecognized(name, value)
 	}
 
 	if err != nil {
-		return err
+		return HeaderError{Name: canonical, Value: value, Err: err}
 	}
 
 	if rh.raw == nil {
@@ -267,6 +769,37 @@ func (rh *RequestHeaders) setHeader(name, value string) error {
 	return nil
 }
 
+// canonicalHeaderName upper-cases the first letter of each "-" separated word in name, and lower-cases the rest, so
+// "content-TYPE" becomes "Content-Type".
+func canonicalHeaderName(name string) string {
+	upper := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if (upper && 'a' <= c && c <= 'z') || (!upper && 'A' <= c && c <= 'Z') {
+			return canonicalizeHeaderName(name)
+		}
+		upper = c == '-'
+	}
+
+	return name
+}
+
+func canonicalizeHeaderName(name string) string {
+	b := []byte(name)
+	upper := true
+
+	for i, c := range b {
+		if upper && 'a' <= c && c <= 'z' {
+			b[i] = c - ('a' - 'A')
+		} else if !upper && 'A' <= c && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+		upper = c == '-'
+	}
+
+	return string(b)
+}
+
 func (rh *RequestHeaders) setDate(data string) error {
 	date, err := constructs.ParseDate(data)
 	if err != nil {
@@ -287,6 +820,88 @@ func (rh *RequestHeaders) setPragma(data string) error {
 	return nil
 }
 
+func (rh *RequestHeaders) setCacheControl(data string) error {
+	cacheControl, err := parseCacheControlDirectives(data)
+	if err != nil {
+		return fmt.Errorf("Invalid Cache-Control header: %s", err.Error())
+	}
+
+	rh.CacheControl = cacheControl
+	return nil
+}
+
+func parseCacheControlDirectives(data string) (CacheControl, error) {
+	cacheControl := CacheControl{Options: make(map[string]string), Flags: make(map[string]bool)}
+
+	for _, part := range rules.Extract(data) {
+		err := cacheControl.add(part)
+		if err != nil {
+			return cacheControl, err
+		}
+	}
+
+	return cacheControl, nil
+}
+
+func (cc *CacheControl) add(directive string) error {
+	values := strings.SplitN(directive, "=", 2)
+	err := constructs.ValidateToken(values[0])
+	if err != nil {
+		return fmt.Errorf("cache directive must be prepended with token: %s", directive)
+	}
+	key := strings.ToLower(values[0])
+
+	if len(values) == 1 {
+		if isDeltaSecondsCacheDirective(key) && key != "max-stale" {
+			return fmt.Errorf("cache directive '%s' requires a value (%s)", key, directive)
+		}
+
+		delete(cc.Options, key)
+		cc.Flags[key] = true
+		return nil
+	}
+
+	value, err := constructs.ParseWord(values[1])
+	if err != nil {
+		return fmt.Errorf("cache directive value must be a word: %s", directive)
+	}
+
+	if isDeltaSecondsCacheDirective(key) {
+		_, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("cache directive '%s' value must be a number of seconds (%s)", key, directive)
+		}
+	}
+
+	delete(cc.Flags, key)
+	cc.Options[key] = value
+	return nil
+}
+
+func isDeltaSecondsCacheDirective(key string) bool {
+	switch key {
+	case "max-age", "s-maxage", "max-stale", "min-fresh":
+		return true
+	}
+	return false
+}
+
+func (rh *RequestHeaders) setConnection(data string) error {
+	var options ConnectionOptions
+
+	for _, option := range rules.Extract(data) {
+		err := constructs.ValidateToken(option)
+		if err != nil {
+			return fmt.Errorf("Invalid Connection header: malformed connection option (%s)", data)
+		}
+
+		options = append(options, option)
+	}
+
+	rh.Connection = options
+	return nil
+}
+
 func parsePragmaDirectives(data string) (PragmaDirectives, error) {
 	directives := PragmaDirectives{Options: make(map[string]string), Flags: make(map[string]bool)}
 	parts := rules.Extract(data)
@@ -325,7 +940,7 @@ func parsePragmaDirectives(data string) (PragmaDirectives, error) {
 }
 
 func (rh *RequestHeaders) setReferer(data string) error {
-	uri, err := parseUri([]byte(data))
+	uri, err := parseUriWithFragment([]byte(data))
 	if err != nil {
 		return fmt.Errorf("Invalid Referer header: %s", err.Error())
 	}
@@ -334,6 +949,229 @@ func (rh *RequestHeaders) setReferer(data string) error {
 	return nil
 }
 
+func (rh *RequestHeaders) setHost(data string) error {
+	data = lws.TrimRight(data)
+	name, port, err := parseHostPort(data)
+	if err != nil {
+		return fmt.Errorf("Invalid Host header: %s", err.Error())
+	}
+
+	rh.Host = data
+	rh.hostName = name
+	rh.port = port
+	return nil
+}
+
+func parseHostPort(data string) (string, int, error) {
+	name := data
+	port := ""
+
+	i := strings.LastIndexByte(data, ':')
+	if i != -1 && !strings.HasSuffix(data, "]") {
+		name = data[:i]
+		port = data[i+1:]
+	}
+
+	err := validateHostName(name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(port) == 0 {
+		return name, 0, nil
+	}
+
+	for _, c := range port {
+		if !constructs.HttpByte(c).IsNumeric() {
+			return "", 0, fmt.Errorf("port must be numeric (%s)", data)
+		}
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n > 65535 {
+		return "", 0, fmt.Errorf("port out of range (%s)", data)
+	}
+
+	return name, n, nil
+}
+
+func validateHostName(data string) error {
+	if len(data) == 0 {
+		return fmt.Errorf("host cannot be empty")
+	}
+
+	if data[0] == '[' {
+		if len(data) < 3 || data[len(data)-1] != ']' {
+			return fmt.Errorf("malformed IP literal (%s)", data)
+		}
+
+		for _, c := range data[1 : len(data)-1] {
+			if !constructs.HttpByte(c).IsHex() && c != ':' && c != '.' {
+				return fmt.Errorf("IP literal contains invalid byte (%s)", data)
+			}
+		}
+
+		return nil
+	}
+
+	for _, c := range data {
+		b := constructs.HttpByte(c)
+		if !b.IsAlpha() && !b.IsNumeric() && c != '-' && c != '.' {
+			return fmt.Errorf("host contains invalid byte (%s)", data)
+		}
+	}
+
+	return nil
+}
+
+func (rh *RequestHeaders) setCookie(data string) error {
+	cookies := make(map[string]string)
+
+	for pair := range strings.SplitSeq(data, ";") {
+		pair = lws.Trim(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("Invalid Cookie header: cookie must be a name=value pair (%s)", data)
+		}
+
+		err := constructs.ValidateToken(name)
+		if err != nil {
+			return fmt.Errorf("Invalid Cookie header: malformed cookie name (%s)", data)
+		}
+
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+
+		err = constructs.ValidateCookieValue(value)
+		if err != nil {
+			return fmt.Errorf("Invalid Cookie header: %s", err.Error())
+		}
+
+		_, exists := cookies[name]
+		if !exists {
+			cookies[name] = value
+		}
+	}
+
+	rh.cookies = cookies
+	return nil
+}
+
+func (rh *RequestHeaders) setRange(data string) error {
+	// a server may ignore a Range header, so one that cannot be served as a single byte range is dropped and the full
+	// entity is served instead: other units, multiple ranges, and malformed specs alike
+	unit, spec, found := strings.Cut(lws.Trim(data), "=")
+	if !found || unit != "bytes" || strings.Contains(spec, ",") {
+		return nil
+	}
+
+	byteRange, err := parseByteRange(spec)
+	if err != nil {
+		return nil
+	}
+
+	rh.Range = byteRange
+	return nil
+}
+
+func parseByteRange(data string) (ByteRange, error) {
+	byteRange := ByteRange{Unit: "bytes", Start: -1, End: -1}
+
+	first, last, found := strings.Cut(data, "-")
+	if !found || (len(first) == 0 && len(last) == 0) {
+		return byteRange, fmt.Errorf("malformed byte range (%s)", data)
+	}
+
+	if len(first) == 0 {
+		n, err := parseBytePosition(last)
+		if err != nil {
+			return byteRange, err
+		}
+
+		byteRange.SuffixLength = n
+		return byteRange, nil
+	}
+
+	start, err := parseBytePosition(first)
+	if err != nil {
+		return byteRange, err
+	}
+	byteRange.Start = start
+
+	if len(last) > 0 {
+		end, err := parseBytePosition(last)
+		if err != nil {
+			return byteRange, err
+		}
+
+		if end < start {
+			return byteRange, fmt.Errorf("last byte position is before first byte position (%s)", data)
+		}
+		byteRange.End = end
+	}
+
+	return byteRange, nil
+}
+
+func parseBytePosition(data string) (int64, error) {
+	for _, c := range data {
+		if !constructs.HttpByte(c).IsNumeric() {
+			return 0, fmt.Errorf("byte position must be numeric (%s)", data)
+		}
+	}
+
+	n, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("byte position out of range (%s)", data)
+	}
+
+	return n, nil
+}
+
+func (rh *RequestHeaders) setMimeVersion(data string) error {
+	major, minor, ok := strings.Cut(data, ".")
+	if !ok {
+		return fmt.Errorf("Invalid MIME-Version header: must be of the form <major>.<minor> (%s)", data)
+	}
+
+	majorVersion, err := parseVersionNumber(major)
+	if err != nil {
+		return fmt.Errorf("Invalid MIME-Version header: major version %s (%s)", err.Error(), data)
+	}
+
+	minorVersion, err := parseVersionNumber(minor)
+	if err != nil {
+		return fmt.Errorf("Invalid MIME-Version header: minor version %s (%s)", err.Error(), data)
+	}
+
+	rh.MimeVersion = MimeVersion{Major: majorVersion, Minor: minorVersion}
+	return nil
+}
+
+func parseVersionNumber(s string) (int, error) {
+	if len(s) == 0 {
+		return 0, fmt.Errorf("cannot be empty")
+	}
+
+	for _, c := range []byte(s) {
+		if !constructs.HttpByte(c).IsNumeric() {
+			return 0, fmt.Errorf("must only contain digits")
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("is out of range")
+	}
+
+	return n, nil
+}
+
 func (rh *RequestHeaders) setAuthorization(data string) error {
 	authorization, err := parseAuthorizationCredentials(data)
 	if err != nil {
@@ -382,6 +1220,16 @@ func (ac *AuthorizationCredentials) setParams(data string) error {
 		return err
 	}
 
+	if ac.Scheme == "Bearer" {
+		err := ac.setBearerSchemeParams(data)
+		return err
+	}
+
+	if ac.Scheme == "Digest" {
+		err := ac.setDigestSchemeParams(data)
+		return err
+	}
+
 	for i, param := range rules.Extract(data) {
 		parts := strings.SplitN(param, "=", 2)
 		if len(parts) != 2 {
@@ -417,22 +1265,89 @@ func (ac *AuthorizationCredentials) setBasicSchemeParams(data string) error {
 		return fmt.Errorf("invalid credentials")
 	}
 
-	userid := parts[0]
-	err = constructs.ValidateToken(userid)
-	if err != nil && len(userid) > 0 {
-		return fmt.Errorf("invalid credentials")
+	userid := parts[0]
+	err = constructs.ValidateToken(userid)
+	if err != nil && len(userid) > 0 {
+		return fmt.Errorf("invalid credentials")
+	}
+
+	password := parts[1]
+	err = constructs.ValidateText(password)
+	if err != nil {
+		return fmt.Errorf("invalid credentials")
+	}
+
+	params := make(map[string]string)
+	params["userid"] = userid
+	params["password"] = password
+	ac.Parameters = params
+
+	return nil
+}
+
+func (ac *AuthorizationCredentials) setBearerSchemeParams(data string) error {
+	token := lws.TrimRight(data)
+	err := constructs.ValidateToken68(token)
+	if err != nil {
+		return fmt.Errorf("invalid bearer token: %s", err.Error())
+	}
+
+	ac.Parameters = map[string]string{"token": token}
+	return nil
+}
+
+func (ac *AuthorizationCredentials) setDigestSchemeParams(data string) error {
+	params := make(map[string]string)
+
+	for i, param := range rules.Extract(data) {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid digest parameter (param %d [%s])", i, data)
+		}
+
+		key := parts[0]
+		err := constructs.ValidateToken(key)
+		if err != nil {
+			return fmt.Errorf("invalid digest parameter (param %d [%s])", i, data)
+		}
+
+		val, err := constructs.ParseWord(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid digest parameter (param %d [%s])", i, data)
+		}
+
+		params[key] = val
+	}
+
+	for _, name := range []string{"username", "realm", "nonce", "uri", "response"} {
+		_, ok := params[name]
+		if !ok {
+			return fmt.Errorf("missing required digest parameter '%s' (%s)", name, data)
+		}
 	}
 
-	password := parts[1]
-	err = constructs.ValidateText(password)
-	if err != nil {
-		return fmt.Errorf("invalid credentials")
+	algorithm, ok := params["algorithm"]
+	if !ok || strings.EqualFold(algorithm, "MD5") {
+		err := validateDigestResponse(params["response"])
+		if err != nil {
+			return err
+		}
 	}
 
-	params := make(map[string]string)
-	params["userid"] = userid
-	params["password"] = password
 	ac.Parameters = params
+	return nil
+}
+
+func validateDigestResponse(response string) error {
+	if len(response) != 32 {
+		return fmt.Errorf("digest response must be 32 hex characters (%s)", response)
+	}
+
+	for _, c := range response {
+		if !constructs.HttpByte(c).IsNumeric() && (c < 'a' || c > 'f') {
+			return fmt.Errorf("digest response must be lowercase hex (%s)", response)
+		}
+	}
 
 	return nil
 }
@@ -457,6 +1372,16 @@ func (rh *RequestHeaders) setIfModifiedSince(data string) error {
 	return nil
 }
 
+func (rh *RequestHeaders) setIfUnmodifiedSince(data string) error {
+	date, err := constructs.ParseDate(data)
+	if err != nil {
+		return fmt.Errorf("Invalid If-Unmodified-Since header: %s", err.Error())
+	}
+
+	rh.IfUnmodifiedSince = MessageTime{date}
+	return nil
+}
+
 func (rh *RequestHeaders) setUserAgent(data string) error {
 	data = lws.TrimLeft(data)
 	i := 0
@@ -494,6 +1419,9 @@ func (rh *RequestHeaders) setUserAgent(data string) error {
 }
 
 func extractComment(data string, start int) (string, int, error) {
+	if start >= len(data) {
+		return "", 0, fmt.Errorf("comment is missing (%s)", data)
+	}
 	if data[start] != '(' {
 		return "", 0, fmt.Errorf("comment must begin with open parenthesis (%s)", data)
 	}
@@ -570,16 +1498,18 @@ func parseProductVersion(data string) (ProductVersion, error) {
 
 func (rh *RequestHeaders) setAllow(data string) error {
 	var methods []Method
-	rules := rules.Extract(data)
-	if len(rules) == 0 {
-		return fmt.Errorf("Invalid Allow header: must include at least one method (%s)", data)
+	if len(lws.Trim(data)) == 0 {
+		return fmt.Errorf("Invalid Allow header: %w (%s)", ErrEmptyAllow, data)
 	}
 
-	for _, m := range rules {
-		err := constructs.ValidateToken(m)
+	for _, m := range rules.Extract(data) {
+		if len(m) == 0 {
+			return fmt.Errorf("Invalid Allow header: %w (%s)", ErrEmptyMethod, data)
+		}
 
+		err := constructs.ValidateToken(m)
 		if err != nil {
-			return fmt.Errorf("Invalid Allow header: includes unsupported methods (%s)", data)
+			return fmt.Errorf("Invalid Allow header: %w (%s)", ErrInvalidMethod, data)
 		}
 
 		methods = append(methods, Method(m))
@@ -589,22 +1519,185 @@ func (rh *RequestHeaders) setAllow(data string) error {
 	return nil
 }
 
-func (rh *RequestHeaders) setContentEncoding(data string) error {
-	var encoding ContentEncoding
-	err := constructs.ValidateToken(data)
+func (rh *RequestHeaders) setVia(data string) error {
+	var hops []ViaHop
+
+	for _, entry := range rules.Extract(data) {
+		hop, err := parseViaHop(lws.TrimRight(entry))
+		if err != nil {
+			return fmt.Errorf("Invalid Via header: %s", err.Error())
+		}
+
+		hops = append(hops, hop)
+	}
+
+	rh.Via = hops
+	return nil
+}
+
+func parseViaHop(data string) (ViaHop, error) {
+	hop := ViaHop{}
+
+	protocol, rest, ok := cutLws(data)
+	if !ok {
+		return hop, fmt.Errorf("missing received-by (%s)", data)
+	}
+
+	name, version, hasName := strings.Cut(protocol, "/")
+	if !hasName {
+		name, version = "", protocol
+	}
+	if hasName && constructs.ValidateToken(name) != nil {
+		return hop, fmt.Errorf("bad protocol name (%s)", protocol)
+	}
+	if constructs.ValidateToken(version) != nil {
+		return hop, fmt.Errorf("bad protocol version (%s)", protocol)
+	}
+
+	receivedBy, comment, _ := cutLws(rest)
+	_, _, err := parseHostPort(receivedBy)
+	if err != nil && constructs.ValidateToken(receivedBy) != nil {
+		return hop, fmt.Errorf("received-by must be a host or pseudonym (%s)", receivedBy)
+	}
+
+	if len(comment) > 0 {
+		c, next, err := extractComment(comment, 0)
+		if err != nil {
+			return hop, fmt.Errorf("bad comment - %s", err.Error())
+		}
+		if next != len(comment) {
+			return hop, fmt.Errorf("unexpected data after comment (%s)", comment)
+		}
+
+		err = constructs.ValidateComment(c)
+		if err != nil {
+			return hop, fmt.Errorf("bad comment - %s", err.Error())
+		}
+
+		hop.Comment = c
+	}
+
+	hop.Protocol = protocol
+	hop.ReceivedBy = receivedBy
+	return hop, nil
+}
+
+// cutLws splits data around its first run of LWS, reporting whether there was one.
+func cutLws(data string) (string, string, bool) {
+	i := strings.IndexAny(data, " \t\r")
+	if i == -1 {
+		return data, "", false
+	}
+
+	return data[:i], lws.TrimLeft(data[i:]), true
+}
+
+func (rh *RequestHeaders) setAcceptEncoding(data string) error {
+	list, err := rules.QList(data)
 	if err != nil {
-		return fmt.Errorf("Invalid Content-Encoding header: malformed value (%s)", data)
+		return fmt.Errorf("Invalid Accept-Encoding header: %s", err.Error())
 	}
 
-	lower := ContentEncoding(strings.ToLower(data))
-	err = lower.Validate()
-	if err == nil {
-		encoding = lower
-	} else {
-		encoding = ContentEncoding(data)
+	encodings := []AcceptedEncoding{}
+	for _, element := range list {
+		err := constructs.ValidateToken(element.Value)
+		if err != nil {
+			return fmt.Errorf("Invalid Accept-Encoding header: malformed content-coding (%s)", element.Value)
+		}
+
+		encodings = append(encodings, AcceptedEncoding{Encoding: ContentEncoding(strings.ToLower(element.Value)), Quality: element.Q})
+	}
+
+	rh.AcceptEncoding = encodings
+	return nil
+}
+
+func (rh *RequestHeaders) setAcceptCharset(data string) error {
+	list, err := rules.QList(data)
+	if err != nil {
+		return fmt.Errorf("Invalid Accept-Charset header: %s", err.Error())
+	}
+
+	charsets := []AcceptedValue{}
+	for _, element := range list {
+		err := constructs.ValidateToken(element.Value)
+		if err != nil {
+			return fmt.Errorf("Invalid Accept-Charset header: malformed charset (%s)", element.Value)
+		}
+
+		charsets = append(charsets, AcceptedValue{Value: strings.ToLower(element.Value), Quality: element.Q})
+	}
+
+	rh.AcceptCharset = charsets
+	return nil
+}
+
+func (rh *RequestHeaders) setAcceptLanguage(data string) error {
+	list, err := rules.QList(data)
+	if err != nil {
+		return fmt.Errorf("Invalid Accept-Language header: %s", err.Error())
+	}
+
+	languages := []AcceptedValue{}
+	for _, element := range list {
+		err := validateLanguageRange(element.Value)
+		if err != nil {
+			return fmt.Errorf("Invalid Accept-Language header: %s", err.Error())
+		}
+
+		languages = append(languages, AcceptedValue{Value: element.Value, Quality: element.Q})
+	}
+
+	rh.AcceptLanguage = languages
+	return nil
+}
+
+// validateLanguageRange accepts "*", or a primary tag of 1 to 8 letters followed by any number of subtags of 1 to 8
+// letters or digits, each introduced by "-".
+func validateLanguageRange(data string) error {
+	if data == "*" {
+		return nil
+	}
+
+	for i, subtag := range strings.Split(data, "-") {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			return fmt.Errorf("language subtags must be 1 to 8 characters (%s)", data)
+		}
+
+		for _, c := range []byte(subtag) {
+			b := constructs.HttpByte(c)
+			if !b.IsAlpha() && (i == 0 || !b.IsNumeric()) {
+				return fmt.Errorf("malformed language range (%s)", data)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (rh *RequestHeaders) setContentEncoding(data string) error {
+	var encodings []ContentEncoding
+	parts := rules.Extract(data)
+
+	for _, part := range parts {
+		err := constructs.ValidateToken(part)
+		if err != nil {
+			return fmt.Errorf("Invalid Content-Encoding header: malformed value (%s)", data)
+		}
+
+		encoding := ContentEncoding(part)
+		lower := ContentEncoding(strings.ToLower(part))
+		err = lower.Validate()
+		if err == nil {
+			encoding = lower
+		} else if len(parts) > 1 {
+			return fmt.Errorf("Invalid Content-Encoding header: unknown coding in layered codings (%s)", data)
+		}
+
+		encodings = append(encodings, encoding)
 	}
 
-	rh.ContentEncoding = encoding
+	rh.ContentEncoding = encodings
 	return nil
 }
 
@@ -618,6 +1711,29 @@ func (rh *RequestHeaders) setContentLength(data string) error {
 	return nil
 }
 
+func (rh *RequestHeaders) setTransferEncoding(data string) error {
+	var codings []string
+
+	for _, coding := range rules.Extract(data) {
+		name, _, _ := strings.Cut(coding, ";")
+		name = lws.TrimRight(name)
+
+		err := constructs.ValidateToken(name)
+		if err != nil {
+			return fmt.Errorf("Invalid Transfer-Encoding header: malformed transfer coding (%s)", data)
+		}
+
+		codings = append(codings, strings.ToLower(name))
+	}
+
+	if len(codings) == 0 {
+		return fmt.Errorf("Invalid Transfer-Encoding header: at least one transfer coding is required")
+	}
+
+	rh.TransferEncoding = codings
+	return nil
+}
+
 func (rh *RequestHeaders) setContentType(data string) error {
 	contentType, err := parseContentType(data)
 	if err != nil {
@@ -698,15 +1814,17 @@ func parseContentTypeParameters(data string) (map[string]string, error) {
 				v = append(v, data[i])
 				i++
 			}
-			if i < len(data) {
-				v = append(v, data[i])
-				i++
+			if i >= len(data) {
+				return nil, fmt.Errorf("unterminated quoted-string (%s)", data)
 			}
+			v = append(v, data[i])
+			i++
 
 			value, err = constructs.ParseQuotedString(string(v))
 			if err != nil {
 				return nil, err
 			}
+			value = lws.Unfold(value)
 
 			isLws, next = lws.Check(data, i)
 			for isLws {
@@ -727,7 +1845,7 @@ func parseContentTypeParameters(data string) (map[string]string, error) {
 			}
 		}
 
-		parameters[string(attribute)] = value
+		parameters[strings.ToLower(string(attribute))] = value
 		i++
 	}
 
@@ -735,13 +1853,142 @@ func parseContentTypeParameters(data string) (map[string]string, error) {
 
 }
 
+func (rh *RequestHeaders) setLink(data string) error {
+	var links []LinkRelation
+
+	for _, entry := range splitLinks(data) {
+		link, err := parseLinkRelation(lws.Trim(entry))
+		if err != nil {
+			return fmt.Errorf("Invalid Link header: %s", err.Error())
+		}
+
+		links = append(links, link)
+	}
+
+	rh.Link = links
+	return nil
+}
+
+// splitLinks splits a Link header on the commas between its entries, ignoring commas inside a URI or quoted string.
+func splitLinks(data string) []string {
+	var links []string
+	inUri, inQuotes := false, false
+	start := 0
+
+	for i := 0; i < len(data); i++ {
+		switch {
+		case inQuotes:
+			if data[i] == '\\' {
+				i++
+			} else if data[i] == '"' {
+				inQuotes = false
+			}
+		case inUri:
+			inUri = data[i] != '>'
+		case data[i] == '"':
+			inQuotes = true
+		case data[i] == '<':
+			inUri = true
+		case data[i] == ',':
+			links = append(links, data[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(links, data[start:])
+}
+
+func parseLinkRelation(data string) (LinkRelation, error) {
+	link := LinkRelation{}
+	if len(data) == 0 || data[0] != '<' {
+		return link, fmt.Errorf("URI must be enclosed in angle brackets (%s)", data)
+	}
+
+	end := strings.IndexByte(data, '>')
+	if end == -1 {
+		return link, fmt.Errorf("URI must be enclosed in angle brackets (%s)", data)
+	}
+
+	uri, err := parseUri([]byte(data[1:end]))
+	if err != nil {
+		return link, fmt.Errorf("malformed URI (%s)", data)
+	}
+	link.Uri = uri
+
+	params := lws.TrimLeft(data[end+1:])
+	if len(params) == 0 {
+		return link, nil
+	}
+	if params[0] != ';' {
+		return link, fmt.Errorf("parameters must follow a ; (%s)", data)
+	}
+
+	link.Parameters, err = parseContentTypeParameters(params[1:])
+	if err != nil {
+		return link, err
+	}
+
+	return link, nil
+}
+
+func (rh *RequestHeaders) setContentMD5(data string) error {
+	digest, err := base64.StdEncoding.DecodeString(lws.TrimRight(data))
+	if err != nil {
+		return fmt.Errorf("Invalid Content-MD5 header: malformed base64 (%s)", data)
+	}
+	if len(digest) != md5.Size {
+		return fmt.Errorf("Invalid Content-MD5 header: digest must be %d bytes (%s)", md5.Size, data)
+	}
+
+	rh.ContentMD5 = digest
+	return nil
+}
+
+func (rh *RequestHeaders) setForwardedFor(data string) error {
+	var ips []net.IP
+
+	for _, entry := range rules.Extract(data) {
+		entry = lws.TrimRight(entry)
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return fmt.Errorf("Invalid X-Forwarded-For header: invalid IP address (%s)", entry)
+		}
+
+		ips = append(ips, ip)
+	}
+
+	rh.ForwardedFor = ips
+	return nil
+}
+
+func (rh *RequestHeaders) setForwardedProto(data string) error {
+	proto := lws.TrimRight(data)
+	err := constructs.ValidateToken(proto)
+	if err != nil {
+		return fmt.Errorf("Invalid X-Forwarded-Proto header: %s", err.Error())
+	}
+
+	rh.ForwardedProto = strings.ToLower(proto)
+	return nil
+}
+
+func (rh *RequestHeaders) setTitle(data string) error {
+	err := constructs.ValidateText(data)
+	if err != nil {
+		return fmt.Errorf("Invalid Title header: %s", err.Error())
+	}
+
+	rh.Title = data
+	return nil
+}
+
 func (rh *RequestHeaders) setExpires(data string) error {
 	expires, err := constructs.ParseDate(data)
 	if err != nil {
 		return fmt.Errorf("Invalid Expires header: %s", err.Error())
 	}
 
-	rh.Date = MessageTime{expires}
+	rh.Expires = MessageTime{expires}
 	return nil
 
 }
@@ -769,55 +2016,169 @@ func (rh *RequestHeaders) setUnrecognized(name, data string) error {
 	return nil
 }
 
-func parseRequestBody(data []byte, rh RequestHeaders) ([]byte, error) {
-	var body []byte
+func parseForm(data []byte) (map[string][]string, error) {
+	form := make(map[string][]string)
+
+	for pair := range bytes.SplitSeq(data, []byte{'&'}) {
+		if len(pair) == 0 {
+			continue
+		}
+
+		name, value, _ := bytes.Cut(pair, []byte{'='})
+
+		n, err := unescapeFormValue(name)
+		if err != nil {
+			return nil, ClientError{message: fmt.Sprintf("Invalid form name: %s", err.Error())}
+		}
+
+		v, err := unescapeFormValue(value)
+		if err != nil {
+			return nil, ClientError{message: fmt.Sprintf("Invalid form value: %s", err.Error())}
+		}
+
+		form[n] = append(form[n], v)
+	}
+
+	return form, nil
+}
+
+func unescapeFormValue(data []byte) (string, error) {
+	var res []byte
+	i := 0
+
+	for i < len(data) {
+		b := data[i]
+
+		if constructs.HttpByte(b).IsEscape() {
+			c, err := unescapeSequence(data, i)
+			if err != nil {
+				return "", err
+			}
+			i += 3
+			b = c
+		} else {
+			if b == '+' {
+				b = ' '
+			}
+			i++
+		}
+
+		res = append(res, b)
+	}
+
+	return string(res), nil
+}
+
+// parseRequestBody returns the body as it was sent, followed by the body with its content codings removed. Without a
+// Content-Encoding header, both are the same slice. Removing a coding may not yield more than maxDecoded bytes, unless
+// maxDecoded is 0.
+func parseRequestBody(data []byte, rh RequestHeaders, maxDecoded uint64) ([]byte, []byte, error) {
+	var raw []byte
 	length := rh.ContentLength
 
 	if length > ContentLength(len(data)) {
-		return body, ClientError{message: "Content-Length header exceeds body length"}
+		return nil, nil, ClientError{message: "Content-Length header exceeds body length"}
 	}
 
 	for i := range length {
-		body = append(body, data[i])
+		raw = append(raw, data[i])
+	}
+
+	body, err := decodeRequestBody(raw, maxDecoded, rh.ContentEncoding...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return raw, body, nil
+}
+
+func decodeRequestBody(body []byte, max uint64, encodings ...ContentEncoding) ([]byte, error) {
+	var err error
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		body, err = decodeRequestBodyCoding(body, encodings[i], max)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return decodeRequestBody(body, rh.ContentEncoding)
+	return body, nil
 }
 
-func decodeRequestBody(body []byte, encoding ContentEncoding) ([]byte, error) {
+func decodeRequestBodyCoding(body []byte, encoding ContentEncoding, max uint64) ([]byte, error) {
 	var res []byte
 	var err error
 	reader := bytes.NewReader(body)
 
 	switch encoding {
 	case ContentEncodingXGzip, ContentEncodingGZip:
-		res, err = gzipDecode(reader)
+		res, err = gzipDecode(reader, max)
 	case ContentEncodingXCompress, ContentEncodingCompress:
-		res, err = compressDecode(reader)
+		res, err = compressDecode(reader, max)
+	case ContentEncodingDeflate:
+		res, err = deflateDecode(reader, max)
+	case ContentEncodingIdentity:
+		res, err = body, nil
 	default:
 		res, err = io.ReadAll(reader)
 	}
 
-	if err != nil {
+	var clientErr ClientError
+	if err != nil && !errors.As(err, &clientErr) {
 		err = ServerError{message: fmt.Sprintf("unexpected issue decoding body: %s", err.Error())}
 	}
 
 	return res, err
 }
 
-func gzipDecode(r io.Reader) ([]byte, error) {
+func gzipDecode(r io.Reader, max uint64) ([]byte, error) {
 	reader, err := gzip.NewReader(r)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
 
-	return io.ReadAll(reader)
+	return readDecoded(reader, max)
+}
+
+// compress and x-compress use the unix compress LZW layout; compressEncode and compressDecode must share these
+const (
+	compressOrder    = lzw.LSB
+	compressLitWidth = 8
+)
+
+func compressDecode(r io.Reader, max uint64) ([]byte, error) {
+	reader := lzw.NewReader(r, compressOrder, compressLitWidth)
+	defer reader.Close()
+
+	return readDecoded(reader, max)
 }
 
-func compressDecode(r io.Reader) ([]byte, error) {
-	reader := lzw.NewReader(r, lzw.LSB, 8)
+// deflateDecode reads a body in the zlib format, the framing deflateEncode uses for the deflate coding.
+func deflateDecode(r io.Reader, max uint64) ([]byte, error) {
+	reader, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
 	defer reader.Close()
 
-	return io.ReadAll(reader)
+	return readDecoded(reader, max)
+}
+
+// readDecoded reads the output of a decompressor, refusing it once it exceeds max bytes, so that a small body cannot
+// expand without bound. A max of 0 means no limit.
+func readDecoded(r io.Reader, max uint64) ([]byte, error) {
+	if max == 0 {
+		return io.ReadAll(r)
+	}
+
+	res, err := io.ReadAll(io.LimitReader(r, int64(min(max, math.MaxInt64-1))+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(res)) > max {
+		return nil, ClientError{message: fmt.Sprintf("decoded body exceeds max allowed by server: %d", max), status: StatusRequestEntityTooLarge}
+	}
+
+	return res, nil
 }
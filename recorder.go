@@ -0,0 +1,88 @@
+package http
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// ResponseRecorder captures the response a handler writes, for use in tests. Pass the writer returned by Writer to the
+// handler, then inspect the response with Result.
+type ResponseRecorder struct {
+	conn bytes.Buffer
+	rw   *ResponseWriter
+}
+
+// RecordedResponse is the parsed view of a recorded response. Header names are kept as they were sent; a header sent
+// more than once, like Set-Cookie, has one value per line.
+type RecordedResponse struct {
+	StatusCode int
+	Reason     string
+	Headers    map[string][]string
+	Body       []byte
+}
+
+// Header returns the first value of the header name, matched case-insensitively, or "" if it was not sent.
+func (r RecordedResponse) Header(name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) && len(v) > 0 {
+			return v[0]
+		}
+	}
+
+	return ""
+}
+
+// NewRecorder returns a ResponseRecorder whose writer starts out with the same defaults the server gives each request.
+func NewRecorder() *ResponseRecorder {
+	rec := &ResponseRecorder{}
+	rec.rw = &ResponseWriter{
+		response: getDefaultResponse(),
+		conn:     &rec.conn,
+		autoDate: true,
+	}
+
+	return rec
+}
+
+// Writer returns the ResponseWriter to hand to the handler under test.
+func (rec *ResponseRecorder) Writer() *ResponseWriter {
+	return rec.rw
+}
+
+// Result returns the response as it would have been sent. A body streamed with BodyWriter is closed first, so Result
+// should only be called once the handler has returned.
+func (rec *ResponseRecorder) Result() RecordedResponse {
+	if rec.rw.stream != nil {
+		rec.rw.stream.Close()
+		return parseRecordedResponse(rec.conn.Bytes())
+	}
+
+	return parseRecordedResponse(rec.rw.Bytes())
+}
+
+func parseRecordedResponse(data []byte) RecordedResponse {
+	r := RecordedResponse{Headers: make(map[string][]string)}
+
+	head, body, _ := bytes.Cut(data, []byte("\r\n\r\n"))
+	r.Body = body
+
+	lines := strings.Split(string(head), "\r\n")
+	status := strings.SplitN(lines[0], " ", 3)
+	if len(status) > 1 {
+		r.StatusCode, _ = strconv.Atoi(status[1])
+	}
+	if len(status) > 2 {
+		r.Reason = status[2]
+	}
+
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		r.Headers[name] = append(r.Headers[name], strings.TrimSpace(value))
+	}
+
+	return r
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/tony-montemuro/http/internal/assert"
+)
+
+func TestResponseRecorder_Result(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		code    int
+		headers map[string]string
+		body    string
+	}{
+		{"Default", func(r Request, w *ResponseWriter) {}, StatusOK, map[string]string{"Content-Type": "application/octet-stream"}, ""},
+		{"Status headers and body", func(r Request, w *ResponseWriter) {
+			w.SetStatus(StatusCreated)
+			w.SetContentTypeHeader([]byte("text"), []byte("plain"))
+			w.SetHeader([]byte("X-Request-Id"), []byte("42"))
+			w.SetBody([]byte("created"))
+		}, StatusCreated, map[string]string{"Content-Type": "text/plain", "X-Request-Id": "42", "Content-Length": "7"}, "created"},
+		{"Error", func(r Request, w *ResponseWriter) {
+			w.Error(StatusNotFound, "missing")
+		}, StatusNotFound, map[string]string{"Content-Type": "text/plain"}, "missing\n"},
+		{"Streamed", func(r Request, w *ResponseWriter) {
+			bw := w.BodyWriter()
+			bw.Write([]byte("chunk one, "))
+			bw.Write([]byte("chunk two"))
+		}, StatusOK, map[string]string{"Content-Type": "application/octet-stream"}, "chunk one, chunk two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecorder()
+			tt.handler.ServeHTTP(Request{}, rec.Writer())
+			res := rec.Result()
+
+			assert.Equal(t, res.StatusCode, tt.code)
+			assert.Equal(t, res.Reason, StatusText(tt.code))
+			for name, value := range tt.headers {
+				assert.Equal(t, res.Header(name), value)
+			}
+			if res.Header("Date") == "" {
+				t.Errorf("expected a Date header")
+			}
+			assert.Equal(t, string(res.Body), tt.body)
+		})
+	}
+}
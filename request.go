@@ -1,7 +1,17 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
 )
 
 type AuthorizationCredentials struct {
@@ -9,6 +19,16 @@ type AuthorizationCredentials struct {
 	Parameters map[string]string
 }
 
+// BearerToken returns the token sent with the Bearer scheme, and false if the credentials use another scheme.
+func (ac AuthorizationCredentials) BearerToken() (string, bool) {
+	if ac.Scheme != "Bearer" {
+		return "", false
+	}
+
+	token, ok := ac.Parameters["token"]
+	return token, ok
+}
+
 type ProductVersion struct {
 	Product string
 	Version string
@@ -19,39 +39,475 @@ type UserAgent struct {
 	Products []ProductVersion
 }
 
+// ViaHop is one entry of a Via header: the protocol a proxy or gateway received the message with, the host (or
+// pseudonym) that received it, and an optional comment, parentheses included.
+type ViaHop struct {
+	Protocol   string
+	ReceivedBy string
+	Comment    string
+}
+
+// LinkRelation is one entry of a Link header: the linked URI and its parameters, such as rel, rev and title.
+type LinkRelation struct {
+	Uri        Uri
+	Parameters map[string]string
+}
+
+// RequestLine is a parsed Request-Line. AbsoluteUri is only set for requests sent to a proxy with an absoluteURI, which
+// requires Server.AllowAbsoluteURI; Uri then holds its net_path.
 type RequestLine struct {
-	Method  Method
-	Uri     RelativeUri
-	Version string
+	Method      Method
+	Uri         RelativeUri
+	AbsoluteUri *AbsoluteUri
+	Version     string
 }
 
 type RequestHeaders struct {
-	Date            MessageTime
-	Pragma          PragmaDirectives
-	Authorization   AuthorizationCredentials
-	From            mail.Address
-	IfModifiedSince MessageTime
-	Referer         Uri
-	UserAgent       UserAgent
-	Allow           []Method
-	ContentEncoding ContentEncoding
-	ContentLength   ContentLength
-	ContentType     ContentType
-	Expires         MessageTime
-	LastModified    MessageTime
-	Unrecognized    map[string]string
-	raw             map[string]string
+	Date              MessageTime
+	Pragma            PragmaDirectives
+	CacheControl      CacheControl
+	Connection        ConnectionOptions
+	MimeVersion       MimeVersion
+	Authorization     AuthorizationCredentials
+	From              mail.Address
+	IfModifiedSince   MessageTime
+	IfUnmodifiedSince MessageTime
+	Referer           Uri
+	Host              string
+	Range             ByteRange
+	UserAgent         UserAgent
+	Via               []ViaHop
+	Allow             []Method
+	AcceptCharset     []AcceptedValue
+	AcceptEncoding    []AcceptedEncoding
+	AcceptLanguage    []AcceptedValue
+	ContentEncoding   []ContentEncoding
+	ContentLength     ContentLength
+	ContentMD5        []byte
+	ContentType       ContentType
+	TransferEncoding  []string
+	Expires           MessageTime
+	LastModified      MessageTime
+	Link              []LinkRelation
+	Title             string
+	ForwardedFor      []net.IP
+	ForwardedProto    string
+	Unrecognized      map[string]string
+	raw               map[string]string
+	hostName          string
+	port              int
+	cookies           map[string]string
 }
 
 type Body []byte
 
+// Request is a parsed client request. Body has any content codings removed, while RawBody holds the body exactly as it
+// was sent. RemoteAddr is the address of the peer that sent it, when read from a net.Conn.
 type Request struct {
-	Line    RequestLine
-	Headers RequestHeaders
-	Body    Body
+	Line       RequestLine
+	Headers    RequestHeaders
+	Body       Body
+	RawBody    Body
+	RemoteAddr net.Addr
+	isTLS      bool
+	ctx        context.Context
+	body       *bodyReader
+	pathParams map[string]string
+}
+
+// Clone returns a deep copy of r, which the handler may keep, or hand to another goroutine, after it returns. A body too
+// large to be read up front is not copied, and can still only be read from BodyReader while the handler runs.
+func (r Request) Clone() Request {
+	clone := r
+	clone.Line = r.Line.clone()
+	clone.Headers = r.Headers.clone()
+	clone.Body = bytes.Clone(r.Body)
+	clone.RawBody = bytes.Clone(r.RawBody)
+	clone.pathParams = maps.Clone(r.pathParams)
+
+	return clone
+}
+
+func (rl RequestLine) clone() RequestLine {
+	clone := rl
+	clone.Uri = rl.Uri.clone()
+	if rl.AbsoluteUri != nil {
+		absoluteUri := rl.AbsoluteUri.clone()
+		clone.AbsoluteUri = &absoluteUri
+	}
+
+	return clone
+}
+
+func (rh RequestHeaders) clone() RequestHeaders {
+	clone := rh
+	clone.Pragma = PragmaDirectives{Flags: maps.Clone(rh.Pragma.Flags), Options: maps.Clone(rh.Pragma.Options)}
+	clone.CacheControl = CacheControl{Flags: maps.Clone(rh.CacheControl.Flags), Options: maps.Clone(rh.CacheControl.Options)}
+	clone.Connection = slices.Clone(rh.Connection)
+	clone.Authorization.Parameters = maps.Clone(rh.Authorization.Parameters)
+	clone.Referer = cloneUri(rh.Referer)
+	clone.UserAgent = UserAgent{Comments: slices.Clone(rh.UserAgent.Comments), Products: slices.Clone(rh.UserAgent.Products)}
+	clone.Via = slices.Clone(rh.Via)
+	clone.Allow = slices.Clone(rh.Allow)
+	clone.AcceptCharset = slices.Clone(rh.AcceptCharset)
+	clone.AcceptEncoding = slices.Clone(rh.AcceptEncoding)
+	clone.AcceptLanguage = slices.Clone(rh.AcceptLanguage)
+	clone.ContentEncoding = slices.Clone(rh.ContentEncoding)
+	clone.ContentMD5 = bytes.Clone(rh.ContentMD5)
+	clone.ContentType.Parameters = maps.Clone(rh.ContentType.Parameters)
+	clone.TransferEncoding = slices.Clone(rh.TransferEncoding)
+	if rh.ForwardedFor != nil {
+		clone.ForwardedFor = make([]net.IP, len(rh.ForwardedFor))
+		for i, ip := range rh.ForwardedFor {
+			clone.ForwardedFor[i] = slices.Clone(ip)
+		}
+	}
+	clone.Unrecognized = maps.Clone(rh.Unrecognized)
+	clone.raw = maps.Clone(rh.raw)
+	clone.cookies = maps.Clone(rh.cookies)
+
+	if rh.Link != nil {
+		clone.Link = make([]LinkRelation, len(rh.Link))
+		for i, link := range rh.Link {
+			clone.Link[i] = LinkRelation{Uri: cloneUri(link.Uri), Parameters: maps.Clone(link.Parameters)}
+		}
+	}
+
+	return clone
+}
+
+// BodyReader returns a reader over the request body. Bodies larger than Server.StreamBodyThreshold are not read up front,
+// leaving Body and RawBody empty; they can only be read from here, as they were sent, and only while the handler runs.
+func (r Request) BodyReader() io.Reader {
+	if r.body != nil {
+		return r.body
+	}
+
+	return bytes.NewReader(r.Body)
+}
+
+// HostName returns the host named by the Host header, without its port.
+func (rh RequestHeaders) HostName() string {
+	return rh.hostName
+}
+
+// Port returns the port named by the Host header, or 0 if it did not include one.
+func (rh RequestHeaders) Port() int {
+	return rh.port
+}
+
+func (rh RequestHeaders) Cookie(name string) (string, bool) {
+	value, ok := rh.cookies[name]
+	return value, ok
+}
+
+func (rh RequestHeaders) Cookies() map[string]string {
+	cookies := make(map[string]string, len(rh.cookies))
+	maps.Copy(cookies, rh.cookies)
+	return cookies
+}
+
+// VerifyContentMD5 reports whether the body matches the Content-MD5 header. As RFC 1864 specifies, the digest covers the
+// body as it was sent, before any content codings are removed. An error is returned when the request has no Content-MD5
+// header, or its body was streamed and so cannot be checked.
+func (r Request) VerifyContentMD5() (bool, error) {
+	if r.Headers.ContentMD5 == nil {
+		return false, fmt.Errorf("request has no Content-MD5 header")
+	}
+	if r.body != nil {
+		return false, fmt.Errorf("streamed body cannot be verified")
+	}
+
+	digest := md5.Sum(r.RawBody)
+	return bytes.Equal(digest[:], r.Headers.ContentMD5), nil
+}
+
+// ClientIP returns the IP address of the client. If trustProxies is set, and the request came through proxies that sent
+// X-Forwarded-For, it is the left-most address in that header; otherwise it is the direct peer. Only trust proxies when
+// every request reaches the server through them, as clients can send any X-Forwarded-For they like. Returns nil if the
+// peer is unknown, such as for a request parsed from a file.
+func (r Request) ClientIP(trustProxies bool) net.IP {
+	if trustProxies && len(r.Headers.ForwardedFor) > 0 {
+		return r.Headers.ForwardedFor[0]
+	}
+
+	switch addr := r.RemoteAddr.(type) {
+	case nil:
+		return nil
+	case *net.TCPAddr:
+		return addr.IP
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Context returns the request's context. For requests read by a Server, it is canceled when the client closes the
+// connection, or once the handler returns. A request body streamed through BodyReader is not watched, so its read
+// errors report a closed connection instead. Requests without a context return context.Background().
+func (r Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
+// WithContext returns a copy of r with its context replaced by ctx, which must not be nil.
+func (r Request) WithContext(ctx context.Context) Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	r.ctx = ctx
+	return r
+}
+
+// IsTLS reports whether the request was read from a TLS connection, such as one accepted by Server.ServeTLS.
+func (r Request) IsTLS() bool {
+	return r.isTLS
+}
+
+// BasicAuth returns the user-ID and password sent with the Basic scheme, and false if the request has no Basic
+// credentials.
+func (r Request) BasicAuth() (string, string, bool) {
+	ac := r.Headers.Authorization
+	if ac.Scheme != "Basic" {
+		return "", "", false
+	}
+
+	username, hasUsername := ac.Parameters["userid"]
+	password, hasPassword := ac.Parameters["password"]
+	if !hasUsername || !hasPassword {
+		return "", "", false
+	}
+
+	return username, password, true
+}
+
+// IsProxyRequest reports whether the Request-Line named an absoluteURI, as clients do when talking to a proxy.
+func (r Request) IsProxyRequest() bool {
+	return r.Line.AbsoluteUri != nil
+}
+
+// TargetHost returns the host (and port, if any) named by the absoluteURI of a proxy request, or the empty string for
+// any other request.
+func (r Request) TargetHost() string {
+	if !r.IsProxyRequest() {
+		return ""
+	}
+
+	return string(r.Line.Uri.NetLoc)
 }
 
+// URL returns the Request-URI as a *url.URL. Path holds the decoded path, followed by any ;params, and RawQuery the
+// query. Scheme and Host are only set for proxy requests, which name an absoluteURI; otherwise they are empty.
+func (r Request) URL() *url.URL {
+	uri := r.Line.Uri
+	path := uri.Path
+	if len(uri.Params) > 0 {
+		path = fmt.Appendf(bytes.Clone(path), ";%s", bytes.Join(uri.Params, []byte{';'}))
+	}
+
+	u := &url.URL{Path: string(path), RawQuery: string(uri.Query)}
+	if r.IsProxyRequest() {
+		u.Scheme = strings.ToLower(string(r.Line.AbsoluteUri.Scheme))
+		u.Host = string(uri.NetLoc)
+	}
+
+	return u
+}
+
+// IsChunked reports whether the request body was sent with the chunked transfer coding.
+func (rh RequestHeaders) IsChunked() bool {
+	return slices.Contains(rh.TransferEncoding, "chunked")
+}
+
+// RefererString returns the Referer header exactly as it was sent, or the empty string when the request carries none.
+func (rh RequestHeaders) RefererString() string {
+	value, _ := rh.rawHeader("Referer")
+	return value
+}
+
+// WantsClose reports whether the client asked for the connection to be closed once the response is sent.
+func (rh RequestHeaders) WantsClose() bool {
+	return rh.Connection.Has("close")
+}
+
+// WantsKeepAlive reports whether the client asked for the connection to be kept open for further requests. A request
+// listing both close and keep-alive wants the connection closed.
+func (rh RequestHeaders) WantsKeepAlive() bool {
+	return rh.Connection.Has("keep-alive") && !rh.WantsClose()
+}
+
+// EndToEndHeaders returns the raw headers that should be forwarded when proxying the request. Hop-by-hop headers are
+// left out: Connection itself, Keep-Alive, and every header named by a Connection option.
+func (rh RequestHeaders) EndToEndHeaders() map[string]string {
+	headers := make(map[string]string, len(rh.raw))
+
+	for name, value := range rh.raw {
+		if strings.EqualFold(name, "Connection") || strings.EqualFold(name, "Keep-Alive") || rh.Connection.Has(name) {
+			continue
+		}
+
+		headers[name] = value
+	}
+
+	return headers
+}
+
+// PathParam returns the value captured by the :name wildcard of the ServeMux route that matched the request, or the
+// empty string if there is no such wildcard.
+func (r Request) PathParam(name string) string {
+	return r.pathParams[name]
+}
+
+// GetRawHeader returns the value of the header called name, exactly as it was sent. name is matched case-insensitively.
 func (r Request) GetRawHeader(name string) (string, bool) {
-	value, ok := r.Headers.raw[name]
+	return r.Headers.rawHeader(name)
+}
+
+func (rh RequestHeaders) rawHeader(name string) (string, bool) {
+	_, value, ok := rh.lookupRawHeader(name)
 	return value, ok
 }
+
+// lookupRawHeader finds the raw header called name, ignoring case, and returns it along with the name it was sent as.
+func (rh RequestHeaders) lookupRawHeader(name string) (string, string, bool) {
+	value, ok := rh.raw[name]
+	if ok {
+		return name, value, true
+	}
+
+	for key, value := range rh.raw {
+		if strings.EqualFold(key, name) {
+			return key, value, true
+		}
+	}
+
+	return "", "", false
+}
+
+// PreferredEncoding returns the coding from supported that the client accepts with the highest quality value. Ties go
+// to the coding listed first in supported. The empty ContentEncoding (identity) is returned when the request carries no
+// Accept-Encoding header, or when none of the supported codings are acceptable.
+func (rh RequestHeaders) PreferredEncoding(supported []ContentEncoding) ContentEncoding {
+	var preferred ContentEncoding
+	if rh.AcceptEncoding == nil {
+		return preferred
+	}
+
+	best := 0.0
+	identity, hasIdentity := rh.encodingQuality(ContentEncodingIdentity)
+
+	for _, encoding := range supported {
+		q, ok := rh.encodingQuality(ContentEncoding(strings.ToLower(string(encoding))))
+		if ok && q > best {
+			best = q
+			preferred = encoding
+		}
+	}
+
+	if hasIdentity && identity > best {
+		return ContentEncoding("")
+	}
+
+	return preferred
+}
+
+func (rh RequestHeaders) encodingQuality(encoding ContentEncoding) (float64, bool) {
+	wildcard := -1.0
+
+	for _, accepted := range rh.AcceptEncoding {
+		if accepted.Encoding == encoding {
+			return accepted.Quality, true
+		}
+		if accepted.Encoding == "*" {
+			wildcard = accepted.Quality
+		}
+	}
+
+	return wildcard, wildcard >= 0
+}
+
+// PreferredLanguage returns the language tag from supported that the client accepts with the highest quality value. A
+// tag takes the quality of the longest language range matching it, where a range matches the tag itself and any tag
+// beginning with the range followed by "-". Ties go to the tag listed first in supported. Without an Accept-Language
+// header every language is acceptable, so the first supported tag is returned; the empty string is returned when none
+// of the supported tags are acceptable.
+func (rh RequestHeaders) PreferredLanguage(supported []string) string {
+	if rh.AcceptLanguage == nil {
+		if len(supported) == 0 {
+			return ""
+		}
+		return supported[0]
+	}
+
+	preferred := ""
+	best := 0.0
+
+	for _, tag := range supported {
+		q := rh.languageQuality(tag)
+		if q > best {
+			best = q
+			preferred = tag
+		}
+	}
+
+	return preferred
+}
+
+func (rh RequestHeaders) languageQuality(tag string) float64 {
+	quality := 0.0
+	longest := -1
+
+	for _, accepted := range rh.AcceptLanguage {
+		r := accepted.Value
+
+		matched := 0
+		if r != "*" {
+			isPrefix := len(tag) > len(r) && tag[len(r)] == '-' && strings.EqualFold(tag[:len(r)], r)
+			if !strings.EqualFold(tag, r) && !isPrefix {
+				continue
+			}
+			matched = len(r)
+		}
+
+		if matched > longest {
+			quality = accepted.Quality
+			longest = matched
+		}
+	}
+
+	return quality
+}
+
+// ContentType returns the request's media type as "type/subtype", along with its parameters. The media type is empty when
+// the request carries no Content-Type header.
+func (r Request) ContentType() (string, map[string]string) {
+	ct := r.Headers.ContentType
+	if ct.Type == "" {
+		return "", nil
+	}
+
+	return ct.Type + "/" + ct.Subtype, ct.Parameters
+}
+
+// Charset returns the charset parameter of the request's Content-Type header, or the empty string when there is none.
+func (r Request) Charset() string {
+	return r.Headers.ContentType.Parameters["charset"]
+}
+
+// ParseForm decodes an application/x-www-form-urlencoded body into its name/value pairs. Names that appear more than
+// once keep every value, in the order they were sent.
+func (r Request) ParseForm() (map[string][]string, error) {
+	ct := r.Headers.ContentType
+	if !strings.EqualFold(ct.Type, "application") || !strings.EqualFold(ct.Subtype, "x-www-form-urlencoded") {
+		return nil, fmt.Errorf("body is not form encoded (%s/%s)", ct.Type, ct.Subtype)
+	}
+
+	return parseForm(r.Body)
+}
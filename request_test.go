@@ -0,0 +1,658 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tony-montemuro/http/internal/assert"
+)
+
+func TestRequestHeaders_PreferredEncoding(t *testing.T) {
+	supported := []ContentEncoding{ContentEncodingGZip, ContentEncodingXCompress}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		supported      []ContentEncoding
+		expected       ContentEncoding
+	}{
+		{
+			name:           "No Accept-Encoding header",
+			acceptEncoding: "",
+			supported:      supported,
+			expected:       ContentEncoding(""),
+		},
+		{
+			name:           "First supported coding wins a tie",
+			acceptEncoding: "x-compress, gzip",
+			supported:      supported,
+			expected:       ContentEncoding("gzip"),
+		},
+		{
+			name:           "Higher q value wins",
+			acceptEncoding: "gzip;q=0.4, x-compress;q=0.5",
+			supported:      supported,
+			expected:       ContentEncoding("x-compress"),
+		},
+		{
+			name:           "Unsupported coding ignored",
+			acceptEncoding: "br, x-compress;q=0.2",
+			supported:      supported,
+			expected:       ContentEncoding("x-compress"),
+		},
+		{
+			name:           "Wildcard matches supported coding",
+			acceptEncoding: "*",
+			supported:      supported,
+			expected:       ContentEncoding("gzip"),
+		},
+		{
+			name:           "Explicit exclusion beats wildcard",
+			acceptEncoding: "gzip;q=0, *;q=0.3",
+			supported:      supported,
+			expected:       ContentEncoding("x-compress"),
+		},
+		{
+			name:           "Identity preferred over codings",
+			acceptEncoding: "identity, gzip;q=0.5",
+			supported:      supported,
+			expected:       ContentEncoding(""),
+		},
+		{
+			name:           "Identity excluded",
+			acceptEncoding: "identity;q=0, x-compress;q=0.1",
+			supported:      supported,
+			expected:       ContentEncoding("x-compress"),
+		},
+		{
+			name:           "Nothing acceptable",
+			acceptEncoding: "gzip;q=0, x-compress;q=0",
+			supported:      supported,
+			expected:       ContentEncoding(""),
+		},
+		{
+			name:           "No supported codings",
+			acceptEncoding: "gzip",
+			supported:      nil,
+			expected:       ContentEncoding(""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := RequestHeaders{}
+			if len(tt.acceptEncoding) > 0 {
+				err := headers.setAcceptEncoding(tt.acceptEncoding)
+				if err != nil {
+					t.Fatalf("Test could not complete! (%s)", err.Error())
+				}
+			}
+
+			assert.Equal(t, headers.PreferredEncoding(tt.supported), tt.expected)
+		})
+	}
+}
+
+func TestRequest_ParseForm(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType ContentType
+		body        string
+		expected    map[string][]string
+		expectError bool
+	}{
+		{
+			name:        "Form content type",
+			contentType: ContentType{Type: "application", Subtype: "x-www-form-urlencoded"},
+			body:        "a=1+2&b=3",
+			expected: map[string][]string{
+				"a": {"1 2"},
+				"b": {"3"},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Form content type with non-standard casing",
+			contentType: ContentType{Type: "Application", Subtype: "X-WWW-Form-Urlencoded"},
+			body:        "a=1",
+			expected: map[string][]string{
+				"a": {"1"},
+			},
+			expectError: false,
+		},
+		{
+			name:        "Other content type",
+			contentType: ContentType{Type: "text", Subtype: "plain"},
+			body:        "a=1",
+			expectError: true,
+		},
+		{
+			name:        "No content type",
+			body:        "a=1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Headers: RequestHeaders{ContentType: tt.contentType}, Body: Body(tt.body)}
+			res, err := r.ParseForm()
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, len(res), len(tt.expected))
+			for name, values := range tt.expected {
+				assert.SliceEqual(t, res[name], values)
+			}
+		})
+	}
+}
+
+func TestRequest_Clone(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go func() {
+		server.Write([]byte("POST /a/b;p?q=1 HTTP/1.0\r\n" +
+			"Allow: GET\r\n" +
+			"Cookie: session=abc\r\n" +
+			"Content-Type: text/plain; charset=utf-8\r\n" +
+			"Link: </next>; rel=next\r\n" +
+			"Referer: /prev\r\n" +
+			"X-Custom: a\r\n" +
+			"Content-Length: 5\r\n\r\nhello"))
+	}()
+
+	original, err := ParseRequest(client, Server{ReadTimeout: 5000, MaxHeaderBytes: 4000, MaxBodyBytes: 64000}.parseOptions())
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	original.pathParams = map[string]string{"id": "1"}
+
+	clone := original.Clone()
+	clone.Line.Uri.Path[1] = 'z'
+	clone.Line.Uri.Params[0][0] = 'z'
+	clone.Headers.Allow[0] = MethodPost
+	clone.Headers.cookies["session"] = "changed"
+	clone.Headers.ContentType.Parameters["charset"] = "latin1"
+	clone.Headers.Link[0].Parameters["rel"] = "prev"
+	clone.Headers.Referer.(RelativeUri).Path[1] = 'z'
+	clone.Headers.Unrecognized["X-Custom"] = "b"
+	clone.Headers.raw["X-Custom"] = "b"
+	clone.pathParams["id"] = "2"
+	clone.Body[0] = 'j'
+
+	assert.Equal(t, string(original.Line.Uri.Path), "/a/b")
+	assert.Equal(t, string(original.Line.Uri.Params[0]), "p")
+	assert.SliceEqual(t, original.Headers.Allow, []Method{MethodGet})
+	assert.Equal(t, original.Headers.cookies["session"], "abc")
+	assert.Equal(t, original.Charset(), "utf-8")
+	assert.Equal(t, original.Headers.Link[0].Parameters["rel"], "next")
+	assert.Equal(t, string(original.Headers.Referer.marshal()), "/prev")
+	assert.Equal(t, original.Headers.Unrecognized["X-Custom"], "a")
+	assert.Equal(t, original.PathParam("id"), "1")
+	assert.Equal(t, string(original.Body), "hello")
+
+	value, ok := clone.GetRawHeader("X-Custom")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, value, "b")
+}
+
+func TestRequest_CloneBody(t *testing.T) {
+	original := Request{Body: Body("hello"), RawBody: Body("hello")}
+	clone := original.Clone()
+
+	assert.Equal(t, string(clone.Body), "hello")
+	assert.Equal(t, &clone.Body[0] != &original.Body[0], true)
+	assert.Equal(t, &clone.RawBody[0] != &original.RawBody[0], true)
+}
+
+func TestRequest_ContentType(t *testing.T) {
+	tests := []struct {
+		name               string
+		contentType        string
+		expectedMediaType  string
+		expectedParameters map[string]string
+		expectedCharset    string
+	}{
+		{
+			name:               "With charset",
+			contentType:        "text/html; charset=utf-8",
+			expectedMediaType:  "text/html",
+			expectedParameters: map[string]string{"charset": "utf-8"},
+			expectedCharset:    "utf-8",
+		},
+		{
+			name:               "Without parameters",
+			contentType:        "application/json",
+			expectedMediaType:  "application/json",
+			expectedParameters: map[string]string{},
+			expectedCharset:    "",
+		},
+		{
+			name:               "Uppercase charset parameter",
+			contentType:        "text/plain; Charset=ISO-8859-1",
+			expectedMediaType:  "text/plain",
+			expectedParameters: map[string]string{"charset": "ISO-8859-1"},
+			expectedCharset:    "ISO-8859-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := RequestHeaders{}
+			err := headers.setContentType(tt.contentType)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			r := Request{Headers: headers}
+			mediaType, parameters := r.ContentType()
+
+			assert.Equal(t, mediaType, tt.expectedMediaType)
+			assert.MapEqual(t, parameters, tt.expectedParameters)
+			assert.Equal(t, r.Charset(), tt.expectedCharset)
+		})
+	}
+}
+
+func TestAuthorizationCredentials_BearerToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		credentials   AuthorizationCredentials
+		expected      string
+		expectedFound bool
+	}{
+		{
+			name:          "Bearer credentials",
+			credentials:   AuthorizationCredentials{Scheme: "Bearer", Parameters: map[string]string{"token": "abc"}},
+			expected:      "abc",
+			expectedFound: true,
+		},
+		{
+			name:          "Basic credentials",
+			credentials:   AuthorizationCredentials{Scheme: "Basic", Parameters: map[string]string{"token": "abc"}},
+			expected:      "",
+			expectedFound: false,
+		},
+		{
+			name:          "No credentials",
+			credentials:   AuthorizationCredentials{},
+			expected:      "",
+			expectedFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, ok := tt.credentials.BearerToken()
+
+			assert.Equal(t, ok, tt.expectedFound)
+			assert.Equal(t, token, tt.expected)
+		})
+	}
+}
+
+func TestRequest_TargetHost(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          []byte
+		expected      string
+		expectedProxy bool
+	}{
+		{
+			name:          "Proxy request",
+			line:          []byte("GET http://example.com:8080/foo HTTP/1.0"),
+			expected:      "example.com:8080",
+			expectedProxy: true,
+		},
+		{
+			name:          "Origin request",
+			line:          []byte("GET /foo HTTP/1.0"),
+			expected:      "",
+			expectedProxy: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := parseRequestLine(tt.line, true, 0, nil)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+			r := Request{Line: line}
+
+			assert.Equal(t, r.IsProxyRequest(), tt.expectedProxy)
+			assert.Equal(t, r.TargetHost(), tt.expected)
+		})
+	}
+}
+
+func TestRequest_ClientIP(t *testing.T) {
+	peer := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 54321}
+
+	tests := []struct {
+		name         string
+		headers      string
+		remoteAddr   net.Addr
+		trustProxies bool
+		expected     string
+	}{
+		{"Peer", "", peer, false, "192.0.2.1"},
+		{"Peer with untrusted proxies", "X-Forwarded-For: 203.0.113.7\r\n", peer, false, "192.0.2.1"},
+		{"Single forwarded IP", "X-Forwarded-For: 203.0.113.7\r\n", peer, true, "203.0.113.7"},
+		{"Chain of forwarded IPs", "X-Forwarded-For: 203.0.113.7, 198.51.100.2\r\nX-Forwarded-For: 10.0.0.1\r\n", peer, true, "203.0.113.7"},
+		{"Trusted proxies without header", "", peer, true, "192.0.2.1"},
+		{"Non-TCP peer", "", &net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, false, "<nil>"},
+		{"Unknown peer", "", nil, false, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRequest(strings.NewReader("GET / HTTP/1.0\r\n"+tt.headers+"\r\n"), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+			r.RemoteAddr = tt.remoteAddr
+
+			assert.Equal(t, r.ClientIP(tt.trustProxies).String(), tt.expected)
+		})
+	}
+}
+
+func TestRequest_WithContext(t *testing.T) {
+	r := Request{}
+	assert.Equal(t, r.Context(), context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	withCtx := r.WithContext(ctx)
+	assert.Equal(t, withCtx.Context(), ctx)
+	assert.Equal(t, r.Context(), context.Background())
+
+	cancel()
+	assert.Equal(t, withCtx.Context().Err(), context.Canceled)
+}
+
+func TestRequest_VerifyContentMD5(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    bool
+		expectError bool
+	}{
+		{
+			name:        "Matching digest",
+			data:        "POST / HTTP/1.0\r\nContent-MD5: XrY7u+Ae7tCTyyK7j1rNww==\r\nContent-Length: 11\r\n\r\nhello world",
+			expected:    true,
+			expectError: false,
+		},
+		{
+			name:        "Mismatching digest",
+			data:        "POST / HTTP/1.0\r\nContent-MD5: XrY7u+Ae7tCTyyK7j1rNww==\r\nContent-Length: 11\r\n\r\nhello there",
+			expected:    false,
+			expectError: false,
+		},
+		{
+			name:        "No Content-MD5 header",
+			data:        "POST / HTTP/1.0\r\nContent-Length: 11\r\n\r\nhello world",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRequest(strings.NewReader(tt.data), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			res, err := r.VerifyContentMD5()
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, res, tt.expected)
+		})
+	}
+}
+
+func TestRequest_URL(t *testing.T) {
+	tests := []struct {
+		name             string
+		line             []byte
+		expectedScheme   string
+		expectedHost     string
+		expectedPath     string
+		expectedRawQuery string
+		expectedQuery    url.Values
+	}{
+		{
+			name:             "abs_path",
+			line:             []byte("GET /a/b?x=1 HTTP/1.0"),
+			expectedPath:     "/a/b",
+			expectedRawQuery: "x=1",
+			expectedQuery:    url.Values{"x": {"1"}},
+		},
+		{
+			name:             "abs_path with escapes and params",
+			line:             []byte("GET /a%2Cb;type=a?x=1&x=2 HTTP/1.0"),
+			expectedPath:     "/a,b;type=a",
+			expectedRawQuery: "x=1&x=2",
+			expectedQuery:    url.Values{"x": {"1", "2"}},
+		},
+		{
+			name:             "Proxy absoluteURI",
+			line:             []byte("GET HTTP://example.com:8080/a/b?x=1 HTTP/1.0"),
+			expectedScheme:   "http",
+			expectedHost:     "example.com:8080",
+			expectedPath:     "/a/b",
+			expectedRawQuery: "x=1",
+			expectedQuery:    url.Values{"x": {"1"}},
+		},
+		{
+			name:           "Proxy absoluteURI without path",
+			line:           []byte("GET http://example.com HTTP/1.0"),
+			expectedScheme: "http",
+			expectedHost:   "example.com",
+			expectedPath:   "/",
+			expectedQuery:  url.Values{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := parseRequestLine(tt.line, true, 0, nil)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+			r := Request{Line: line}
+
+			u := r.URL()
+			assert.Equal(t, u.Scheme, tt.expectedScheme)
+			assert.Equal(t, u.Host, tt.expectedHost)
+			assert.Equal(t, u.Path, tt.expectedPath)
+			assert.Equal(t, u.RawQuery, tt.expectedRawQuery)
+
+			query := u.Query()
+			assert.Equal(t, len(query), len(tt.expectedQuery))
+			for name, values := range tt.expectedQuery {
+				assert.SliceEqual(t, query[name], values)
+			}
+		})
+	}
+}
+
+func TestRequestHeaders_WantsKeepAlive(t *testing.T) {
+	tests := []struct {
+		name              string
+		connection        string
+		expectedKeepAlive bool
+		expectedClose     bool
+	}{
+		{
+			name:              "Close",
+			connection:        "close",
+			expectedKeepAlive: false,
+			expectedClose:     true,
+		},
+		{
+			name:              "Keep-Alive",
+			connection:        "Keep-Alive",
+			expectedKeepAlive: true,
+			expectedClose:     false,
+		},
+		{
+			name:              "Multiple options with mixed casing",
+			connection:        "X-Trace, KEEP-alive, Upgrade",
+			expectedKeepAlive: true,
+			expectedClose:     false,
+		},
+		{
+			name:              "Close wins over keep-alive",
+			connection:        "keep-alive, Close",
+			expectedKeepAlive: false,
+			expectedClose:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := RequestHeaders{}
+			err := headers.setConnection(tt.connection)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			assert.Equal(t, headers.WantsKeepAlive(), tt.expectedKeepAlive)
+			assert.Equal(t, headers.WantsClose(), tt.expectedClose)
+		})
+	}
+}
+
+func TestRequestHeaders_EndToEndHeaders(t *testing.T) {
+	headers, err := parseRequestHeaders([]byte("Connection: x-trace, Keep-Alive\r\nKeep-Alive: timeout=5\r\nX-Trace: abc\r\nX-Other: def\r\nUser-Agent: test"), 0, false)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	assert.MapEqual(t, headers.EndToEndHeaders(), map[string]string{"X-Other": "def", "User-Agent": "test"})
+}
+
+func TestRequest_BasicAuth(t *testing.T) {
+	tests := []struct {
+		name             string
+		authorization    string
+		expectedUsername string
+		expectedPassword string
+		expectedFound    bool
+	}{
+		{
+			name:             "Basic credentials",
+			authorization:    "Basic " + base64.StdEncoding.EncodeToString([]byte("tony:open sesame")),
+			expectedUsername: "tony",
+			expectedPassword: "open sesame",
+			expectedFound:    true,
+		},
+		{
+			name:          "Digest credentials",
+			authorization: `Digest username="tony", realm="test", nonce="abc", uri="/", response="0123456789abcdef0123456789abcdef"`,
+			expectedFound: false,
+		},
+		{
+			name:          "No credentials",
+			expectedFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{}
+			if len(tt.authorization) > 0 {
+				err := r.Headers.setAuthorization(tt.authorization)
+				if err != nil {
+					t.Fatalf("Test could not complete! (%s)", err.Error())
+				}
+			}
+
+			username, password, ok := r.BasicAuth()
+
+			assert.Equal(t, ok, tt.expectedFound)
+			assert.Equal(t, username, tt.expectedUsername)
+			assert.Equal(t, password, tt.expectedPassword)
+		})
+	}
+}
+
+func TestRequestHeaders_PreferredLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		supported []string
+		expected  string
+	}{
+		{
+			name:      "No Accept-Language header",
+			supported: []string{"en", "fr"},
+			expected:  "en",
+		},
+		{
+			name:      "Highest q value wins",
+			header:    "fr;q=0.5, de;q=0.9, en;q=0.1",
+			supported: []string{"en", "fr", "de"},
+			expected:  "de",
+		},
+		{
+			name:      "Range matches more specific tag",
+			header:    "en;q=0.8, fr;q=0.5",
+			supported: []string{"fr", "en-US"},
+			expected:  "en-US",
+		},
+		{
+			name:      "Longest matching range sets quality",
+			header:    "en;q=0.9, en-GB;q=0.2, fr;q=0.5",
+			supported: []string{"en-GB", "fr"},
+			expected:  "fr",
+		},
+		{
+			name:      "Wildcard",
+			header:    "da, *;q=0.3",
+			supported: []string{"de", "da"},
+			expected:  "da",
+		},
+		{
+			name:      "Wildcard accepts unlisted tag",
+			header:    "da;q=0, *;q=0.3",
+			supported: []string{"da", "de"},
+			expected:  "de",
+		},
+		{
+			name:      "Nothing acceptable",
+			header:    "ja",
+			supported: []string{"en", "fr"},
+			expected:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := RequestHeaders{}
+			if len(tt.header) > 0 {
+				err := headers.setAcceptLanguage(tt.header)
+				if err != nil {
+					t.Fatalf("Test could not complete! (%s)", err.Error())
+				}
+			}
+
+			assert.Equal(t, headers.PreferredLanguage(tt.supported), tt.expected)
+		})
+	}
+}
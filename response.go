@@ -1,7 +1,16 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tony-montemuro/http/internal/constructs"
@@ -20,43 +29,88 @@ type challenge struct {
 	params map[string]string
 }
 
+type contentRange string
+
+type transferCoding string
+
+type retryAfter struct {
+	date    MessageTime
+	seconds uint64
+	isDelay bool
+}
+
+type Cookie struct {
+	Name     string
+	Value    string
+	Path     string
+	Domain   string
+	Expires  time.Time
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+}
+
 type Methods struct {
 	methods []Method
 }
 
 type responseHeaders struct {
-	date            MessageTime
-	pragma          PragmaDirectives
-	location        Uri
-	server          server
-	wwwAuthenticate challenge
-	allow           Methods
-	contentEncoding ContentEncoding
-	contentLength   ContentLength
-	contentType     ContentType
-	expires         MessageTime
-	lastModified    MessageTime
-	unrecognized    map[string]string
+	date             MessageTime
+	pragma           PragmaDirectives
+	cacheControl     CacheControl
+	connection       ConnectionOptions
+	mimeVersion      MimeVersion
+	location         Uri
+	retryAfter       retryAfter
+	server           server
+	wwwAuthenticate  []challenge
+	allow            Methods
+	contentEncoding  ContentEncoding
+	transferEncoding transferCoding
+	contentLength    ContentLength
+	contentRange     contentRange
+	contentType      ContentType
+	expires          MessageTime
+	lastModified     MessageTime
+	setCookies       []Cookie
+	unrecognized     map[string]string
 }
 
 type responseBody []byte
 
+// response is a response waiting to be marshaled. When head is set it answers a HEAD request, so its headers describe
+// body, but body itself is never sent.
 type response struct {
 	code    code
 	headers responseHeaders
 	body    responseBody
+	head    bool
 }
 
 type ResponseWriter struct {
-	response response
+	response       response
+	conn           io.Writer
+	discardBody    bool
+	chunked        bool
+	version        string
+	writeTimeout   time.Duration
+	defaultServer  ProductVersion
+	stream         *bodyWriter
+	written        bool
+	autoDate       bool
+	contentTypeSet bool
 }
 
 // For the following Status Codes, prefer the associated APIs:
 //
 // 301 Moved Permanently - Redirect(uri)
 // 302 Moved Temporarily - RedirectTemporary(uri)
+// 303 See Other - RedirectSeeOther(uri)
 // 401 Unauhorrized - Unauthorized(scheme, realm)
 func (rw *ResponseWriter) SetStatus(c int) error {
+	if rw.written {
+		return ErrResponseWritten
+	}
 	if StatusText(c) == "" {
 		return fmt.Errorf("not a valid status code")
 	}
@@ -65,22 +119,35 @@ func (rw *ResponseWriter) SetStatus(c int) error {
 	return nil
 }
 
+// Redirect responds with 301 Moved Permanently. uri may be absolute, or relative for a same-origin redirect.
 func (rw *ResponseWriter) Redirect(uri []byte) error {
-	rw.SetStatus(StatusMovedPermanently)
-	return rw.redirect(uri)
+	return rw.redirect(StatusMovedPermanently, uri)
 }
 
+// RedirectTemporary responds with 302 Moved Temporarily. uri may be absolute, or relative for a same-origin redirect.
 func (rw *ResponseWriter) RedirectTemporary(uri []byte) error {
-	rw.SetStatus(StatusMovedTemporarily)
-	return rw.redirect(uri)
+	return rw.redirect(StatusMovedTemporarily, uri)
 }
 
-func (rw *ResponseWriter) redirect(uri []byte) error {
-	err := rw.SetLocation(uri)
+// RedirectSeeOther responds with 303 See Other, directing the client to fetch uri with GET, as is usual after a POST.
+// uri may be absolute, or relative for a same-origin redirect.
+func (rw *ResponseWriter) RedirectSeeOther(uri []byte) error {
+	return rw.redirect(StatusSeeOther, uri)
+}
+
+// redirect responds with c and a Location header of uri. If uri is malformed, the response is left unchanged.
+func (rw *ResponseWriter) redirect(c int, uri []byte) error {
+	var err error
+	if validateStartsWithScheme(uri) == nil {
+		err = rw.SetLocation(uri)
+	} else {
+		err = rw.SetLocationRelative(uri)
+	}
 	if err != nil {
 		return fmt.Errorf("problem redirecting: %s", err.Error())
 	}
 
+	rw.SetStatus(c)
 	rw.SetBody(fmt.Appendf([]byte{}, "Resource moved to %s", uri))
 	return nil
 }
@@ -90,18 +157,65 @@ func (rw *ResponseWriter) Unauthorized(scheme, realm []byte) {
 	rw.SetChallenge(scheme, realm)
 }
 
+// RequireBasicAuth reports whether req carries Basic credentials accepted by check. If it does not, the response is set to
+// 401 Unauthorized with a Basic challenge for realm, and the handler should return. Since check compares secrets, it
+// should do so in constant time, e.g. with crypto/subtle.ConstantTimeCompare.
+func (rw *ResponseWriter) RequireBasicAuth(req Request, realm string, check func(user, pass string) bool) bool {
+	user, pass, ok := req.BasicAuth()
+	if ok && check(user, pass) {
+		return true
+	}
+
+	rw.Unauthorized([]byte("Basic"), []byte(realm))
+	return false
+}
+
 func (rw *ResponseWriter) SetDateHeader(d time.Time) {
 	rw.response.headers.date.date = prepareTime(d)
 }
 
 func (rw *ResponseWriter) SetNoCache(b bool) {
 	if b {
+		if rw.response.headers.pragma.Flags == nil {
+			rw.response.headers.pragma.Flags = make(map[string]bool)
+		}
 		rw.response.headers.pragma.Flags["no-cache"] = true
 	} else {
 		delete(rw.response.headers.pragma.Flags, "no-cache")
 	}
 }
 
+// SetCacheControl replaces the Cache-Control header with directives, each either a bare token (no-store), or a token
+// and value (max-age=60). Repeated directives collapse into one, with the last value winning.
+func (rw *ResponseWriter) SetCacheControl(directives ...string) error {
+	cacheControl := CacheControl{Options: make(map[string]string), Flags: make(map[string]bool)}
+
+	for _, directive := range directives {
+		err := cacheControl.add(directive)
+		if err != nil {
+			return err
+		}
+	}
+
+	rw.response.headers.cacheControl = cacheControl
+	return nil
+}
+
+// SetConnectionClose tells the server to close the connection once this response is sent, even if the client asked
+// for it to be kept alive.
+func (rw *ResponseWriter) SetConnectionClose() {
+	rw.response.headers.connection = ConnectionOptions{"close"}
+}
+
+func (rw *ResponseWriter) SetMimeVersion(major, minor int) error {
+	if major < 0 || minor < 0 {
+		return fmt.Errorf("Invalid MIME-Version header: versions cannot be negative (%d.%d)", major, minor)
+	}
+
+	rw.response.headers.mimeVersion = MimeVersion{Major: major, Minor: minor}
+	return nil
+}
+
 func (rw *ResponseWriter) AddPragmaHeader(name, value []byte) error {
 	sname := string(name)
 	svalue := string(value)
@@ -116,6 +230,9 @@ func (rw *ResponseWriter) AddPragmaHeader(name, value []byte) error {
 		return err
 	}
 
+	if rw.response.headers.pragma.Options == nil {
+		rw.response.headers.pragma.Options = make(map[string]string)
+	}
 	rw.response.headers.pragma.Options[sname] = svalue
 	return nil
 }
@@ -130,6 +247,35 @@ func (rw *ResponseWriter) SetLocation(u []byte) error {
 	return nil
 }
 
+// SetLocationRelative sets the Location header to a relative URI, for redirects within the same origin.
+func (rw *ResponseWriter) SetLocationRelative(u []byte) error {
+	if len(u) == 0 {
+		return fmt.Errorf("location cannot be empty")
+	}
+
+	uri, err := parseRelativeUri(u)
+	if err != nil {
+		return err
+	}
+
+	rw.response.headers.location = uri
+	return nil
+}
+
+// SetRetryAfter tells the client how long to wait before retrying, rounded up to the nearest second.
+func (rw *ResponseWriter) SetRetryAfter(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("retry after cannot be negative")
+	}
+
+	rw.response.headers.retryAfter = retryAfter{seconds: uint64((d + time.Second - 1) / time.Second), isDelay: true}
+	return nil
+}
+
+func (rw *ResponseWriter) SetRetryAfterDate(t time.Time) {
+	rw.response.headers.retryAfter = retryAfter{date: MessageTime{date: prepareTime(t)}}
+}
+
 func (rw *ResponseWriter) AddServerHeader(h []byte) error {
 	pv, err := parseProductVersion(string(h))
 	if err != nil {
@@ -140,6 +286,22 @@ func (rw *ResponseWriter) AddServerHeader(h []byte) error {
 	return nil
 }
 
+// setDefaultServer sets the Server header to pv, unless it is empty or the header was already set.
+func (h *responseHeaders) setDefaultServer(pv ProductVersion) {
+	if pv.Product == "" || len(h.server.products) > 0 || len(h.server.comments) > 0 {
+		return
+	}
+
+	h.server.products = []ProductVersion{pv}
+}
+
+// setDefaultDate stamps the response with the current time, unless the handler already set a date.
+func (h *responseHeaders) setDefaultDate() {
+	if h.date.date.IsZero() {
+		h.date = MessageTime{date: prepareTime(time.Now())}
+	}
+}
+
 func (rw *ResponseWriter) AddServerHeaderComment(c []byte) error {
 	scomment := string(c)
 
@@ -152,6 +314,8 @@ func (rw *ResponseWriter) AddServerHeaderComment(c []byte) error {
 	return nil
 }
 
+// SetChallenge adds a WWW-Authenticate challenge for scheme and realm. It can be called multiple times to offer the
+// client several authentication schemes.
 func (rw *ResponseWriter) SetChallenge(scheme, realm []byte) error {
 	sscheme := string(scheme)
 	srealm := string(realm)
@@ -166,12 +330,18 @@ func (rw *ResponseWriter) SetChallenge(scheme, realm []byte) error {
 		return err
 	}
 
-	rw.response.headers.wwwAuthenticate.scheme = sscheme
-	rw.response.headers.wwwAuthenticate.realm = parsed
+	c := challenge{scheme: sscheme, realm: parsed, params: make(map[string]string)}
+	rw.response.headers.wwwAuthenticate = append(rw.response.headers.wwwAuthenticate, c)
 
 	return nil
 }
 
+// AddChallenge is an alias for SetChallenge.
+func (rw *ResponseWriter) AddChallenge(scheme, realm []byte) error {
+	return rw.SetChallenge(scheme, realm)
+}
+
+// AddChallengeParameter adds an auth-param to the challenge most recently added with SetChallenge.
 func (rw *ResponseWriter) AddChallengeParameter(name, value []byte) error {
 	sname := string(name)
 	svalue := string(value)
@@ -186,20 +356,57 @@ func (rw *ResponseWriter) AddChallengeParameter(name, value []byte) error {
 		return err
 	}
 
-	rw.response.headers.wwwAuthenticate.params[sname] = parsed
+	challenges := rw.response.headers.wwwAuthenticate
+	if len(challenges) == 0 {
+		return fmt.Errorf("no challenge to add parameter to")
+	}
+
+	last := &challenges[len(challenges)-1]
+	if last.params == nil {
+		last.params = make(map[string]string)
+	}
+	last.params[sname] = parsed
 	return nil
 }
 
+// AddAllowHeader adds m to the Allow header, unless it is already listed.
 func (rw *ResponseWriter) AddAllowHeader(m []byte) {
+	if slices.Contains(rw.response.headers.allow.methods, Method(m)) {
+		return
+	}
+
 	rw.response.headers.allow.methods = append(rw.response.headers.allow.methods, Method(m))
 }
 
+// MethodNotAllowed sets the status to 405 Method Not Allowed, and replaces the Allow header with allowed, sorted and
+// without duplicates, as the status requires.
+func (rw *ResponseWriter) MethodNotAllowed(allowed ...Method) error {
+	err := rw.SetStatus(StatusMethodNotAllowed)
+	if err != nil {
+		return err
+	}
+
+	var methods []Method
+	if len(allowed) > 0 {
+		methods = slices.Clone(allowed)
+		slices.Sort(methods)
+		methods = slices.Compact(methods)
+	}
+	rw.response.headers.allow.methods = methods
+	return nil
+}
+
+// SetContentEncoding sets the coding the body is sent with. Since identity applies no transformation, setting it sends no
+// Content-Encoding header.
 func (rw *ResponseWriter) SetContentEncoding(ce []byte) error {
 	encoding := ContentEncoding(ce)
 	err := encoding.Validate()
 	if err != nil {
 		return err
 	}
+	if encoding == ContentEncodingIdentity {
+		encoding = ""
+	}
 
 	rw.response.headers.contentEncoding = encoding
 	return nil
@@ -221,6 +428,7 @@ func (rw *ResponseWriter) SetContentTypeHeader(main, sub []byte) error {
 
 	rw.response.headers.contentType.Type = smain
 	rw.response.headers.contentType.Subtype = ssub
+	rw.contentTypeSet = true
 	return nil
 }
 
@@ -234,18 +442,24 @@ func (rw *ResponseWriter) AddContentTypeHeaderParameter(name, value []byte) erro
 	}
 
 	err = constructs.ValidateToken(svalue)
-	if err == nil {
-		rw.response.headers.contentType.Parameters[sname] = svalue
-		return nil
+	if err != nil {
+		// an empty value would be sent as `name=`, or as `name=""`, neither of which names anything
+		parsed, err := constructs.ParseUserQuotedString(svalue)
+		if parsed == `""` {
+			return fmt.Errorf("parameter value cannot be empty")
+		}
+		if err != nil {
+			return fmt.Errorf("malformed parameter value")
+		}
+		svalue = parsed
 	}
 
-	parsed, err := constructs.ParseUserQuotedString(svalue)
-	if err == nil {
-		rw.response.headers.contentType.Parameters[sname] = parsed
-		return nil
+	if rw.response.headers.contentType.Parameters == nil {
+		rw.response.headers.contentType.Parameters = make(map[string]string)
 	}
-
-	return fmt.Errorf("malformed parameter value")
+	rw.response.headers.contentType.Parameters[sname] = svalue
+	rw.contentTypeSet = true
+	return nil
 }
 
 func (rw *ResponseWriter) SetExpiresHeader(t time.Time) {
@@ -261,12 +475,198 @@ func (rw *ResponseWriter) SetLastModifiedHeader(t time.Time) error {
 	return nil
 }
 
+// NotModifiedIfUnchanged responds with 304 Not Modified and returns true when req carries an If-Modified-Since date that
+// lastMod is not after, so the handler can return early. Dates are compared to the second, and If-Modified-Since dates in
+// the future are ignored.
+func (rw *ResponseWriter) NotModifiedIfUnchanged(lastMod time.Time, req Request) bool {
+	since := req.Headers.IfModifiedSince.date
+	if since.IsZero() || since.After(time.Now()) {
+		return false
+	}
+
+	lastMod = prepareTime(lastMod).Truncate(time.Second)
+	since = prepareTime(since).Truncate(time.Second)
+	if lastMod.After(since) {
+		return false
+	}
+
+	rw.SetStatus(StatusNotModified)
+	rw.SetBody(nil)
+	return true
+}
+
+// PreconditionFailedIfModified responds with 412 Precondition Failed and returns true when req carries an
+// If-Unmodified-Since date that lastMod is after, so the handler can return early. Dates are compared to the second.
+func (rw *ResponseWriter) PreconditionFailedIfModified(lastMod time.Time, req Request) bool {
+	since := req.Headers.IfUnmodifiedSince.date
+	if since.IsZero() {
+		return false
+	}
+
+	lastMod = prepareTime(lastMod).Truncate(time.Second)
+	since = prepareTime(since).Truncate(time.Second)
+	if !lastMod.After(since) {
+		return false
+	}
+
+	rw.SetStatus(StatusPreconditionFailed)
+	rw.SetBody([]byte(StatusText(StatusPreconditionFailed)))
+	return true
+}
+
+// ServeContent responds with content, last modified at modTime. Unless a Content-Type was already set, one is chosen from
+// the extension of name, using a fixed table of common types, or else sniffed from content. When
+// req carries an If-Modified-Since date that modTime is not after, 304 Not Modified is sent instead. A zero or future
+// modTime sends no Last-Modified header, and is never considered unchanged.
+func (rw *ResponseWriter) ServeContent(name string, modTime time.Time, content []byte, req Request) {
+	if !rw.contentTypeSet {
+		rw.response.headers.contentType = contentTypeByName(name, content)
+	}
+
+	if !modTime.IsZero() && rw.SetLastModifiedHeader(modTime) == nil && rw.NotModifiedIfUnchanged(modTime, req) {
+		return
+	}
+
+	rw.SetBody(content)
+}
+
+// ServeFile responds with the file at requestedPath under root, using ServeContent. Paths that escape root are refused
+// with a ClientError whose status is 403 Forbidden, and missing files with one whose status is 404 Not Found. On error,
+// the response is left unchanged.
+func (rw *ResponseWriter) ServeFile(root, requestedPath string, req Request) error {
+	root = filepath.Clean(root)
+	path := filepath.Join(root, filepath.FromSlash(requestedPath))
+
+	prefix := root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	if path != root && !strings.HasPrefix(path, prefix) {
+		return ClientError{message: fmt.Sprintf("path escapes root (%s)", requestedPath), status: StatusForbidden}
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ClientError{message: fmt.Sprintf("file not found (%s)", requestedPath), status: StatusNotFound}
+	}
+	if err != nil {
+		return ServerError{message: fmt.Sprintf("could not stat file: %s", err.Error())}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ServerError{message: fmt.Sprintf("could not read file: %s", err.Error())}
+	}
+
+	rw.ServeContent(info.Name(), info.ModTime(), content, req)
+	return nil
+}
+
+// extensionTypes maps file extensions to the content types ServeContent sends for them. The table is fixed, rather than
+// read from the host's MIME database, so the same file is served the same way everywhere.
+var extensionTypes = map[string]string{
+	".css":  "text/css; charset=utf-8",
+	".gif":  "image/gif",
+	".htm":  "text/html; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+	".ico":  "image/x-icon",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".js":   "text/javascript; charset=utf-8",
+	".json": "application/json",
+	".mjs":  "text/javascript; charset=utf-8",
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".txt":  "text/plain; charset=utf-8",
+	".wasm": "application/wasm",
+	".webp": "image/webp",
+	".xml":  "text/xml; charset=utf-8",
+}
+
+func contentTypeByName(name string, content []byte) ContentType {
+	known, ok := extensionTypes[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return sniffContentType(content)
+	}
+
+	// parsed for every call, so a handler adding parameters never changes the table
+	ct, err := parseContentType(known)
+	if err != nil {
+		return sniffContentType(content)
+	}
+
+	return ct
+}
+
+// SetCookie adds a Set-Cookie header to the response. It can be called multiple times to set multiple cookies. A
+// negative MaxAge deletes the cookie, while a MaxAge of 0 omits the attribute.
+func (rw *ResponseWriter) SetCookie(c Cookie) error {
+	err := constructs.ValidateToken(c.Name)
+	if err != nil {
+		return fmt.Errorf("invalid cookie name: %s", err.Error())
+	}
+
+	err = constructs.ValidateCookieValue(c.Value)
+	if err != nil {
+		return err
+	}
+
+	for _, attribute := range []string{c.Path, c.Domain} {
+		for _, b := range []byte(attribute) {
+			if constructs.HttpByte(b).IsControl() || b == ';' {
+				return fmt.Errorf("cookie attribute contains invalid character (%s)", attribute)
+			}
+		}
+	}
+
+	c.Expires = prepareTime(c.Expires)
+	rw.response.headers.setCookies = append(rw.response.headers.setCookies, c)
+	return nil
+}
+
+// ServePartial responds with the part of full requested by req's Range header, and returns true, when there is one. A
+// range that lies outside of full results in 416 Requested Range Not Satisfiable, which also returns true. When the
+// request has no Range header, the response is left untouched and false is returned.
+func (rw *ResponseWriter) ServePartial(full []byte, req Request) bool {
+	r := req.Headers.Range
+	if r.Unit != "bytes" {
+		return false
+	}
+
+	total := int64(len(full))
+	start, end := r.Start, r.End
+
+	if start < 0 {
+		start = max(0, total-r.SuffixLength)
+		end = total - 1
+	} else if end < 0 || end >= total {
+		end = total - 1
+	}
+
+	if start >= total || (r.Start < 0 && r.SuffixLength == 0) {
+		rw.SetStatus(StatusRequestedRangeNotSatisfiable)
+		rw.response.headers.contentRange = contentRange(fmt.Sprintf("bytes */%d", total))
+		rw.SetBody(nil)
+		return true
+	}
+
+	rw.SetStatus(StatusPartialContent)
+	rw.response.headers.contentRange = contentRange(fmt.Sprintf("bytes %d-%d/%d", start, end, total))
+	rw.SetBody(full[start : end+1])
+	return true
+}
+
 func (rw *ResponseWriter) SetHeader(name, value []byte) error {
+	if rw.written {
+		return ErrResponseWritten
+	}
+
 	sname := string(name)
 	svalue := string(value)
 
 	switch sname {
-	case "Date", "Pragma", "Location", "Server", "WWW-Authenticate", "Allow", "Content-Encoding", "Content-Length", "Content-Type", "Expires", "Last-Modified":
+	case "Date", "Pragma", "Cache-Control", "Connection", "MIME-Version", "Location", "Retry-After", "Server", "WWW-Authenticate", "Allow", "Content-Encoding", "Transfer-Encoding", "Content-Length", "Content-Range", "Content-Type", "Expires", "Last-Modified", "Set-Cookie":
 		return fmt.Errorf("please use API to set %s", name)
 	default:
 		err := validateHeaderName(sname)
@@ -279,6 +679,9 @@ func (rw *ResponseWriter) SetHeader(name, value []byte) error {
 			return err
 		}
 
+		if rw.response.headers.unrecognized == nil {
+			rw.response.headers.unrecognized = make(map[string]string)
+		}
 		rw.response.headers.unrecognized[sname] = svalue
 	}
 
@@ -290,6 +693,241 @@ func (rw *ResponseWriter) SetBody(data []byte) {
 	rw.response.headers.contentLength = ContentLength(len(data))
 }
 
+// Bytes returns the response as the server would send it if the handler returned now, which is useful for comparing a
+// response against golden files. The body is encoded, and left out for HEAD requests and for status codes that do not
+// allow one. The response writer itself is not changed.
+func (rw *ResponseWriter) Bytes() []byte {
+	r := rw.response
+	r.head = rw.discardBody
+
+	err := r.encodeBody()
+	if err != nil {
+		r = getErrorResponse(err)
+	}
+	r.headers.setDefaultServer(rw.defaultServer)
+	if rw.autoDate {
+		r.headers.setDefaultDate()
+	}
+
+	return r.marshal()
+}
+
+// Written reports whether the status line and headers have been sent, either by BodyWriter or by the server once the
+// handler returned. After that, SetStatus and SetHeader return ErrResponseWritten.
+func (rw *ResponseWriter) Written() bool {
+	return rw.written
+}
+
+// Status returns the status code the response will be sent with.
+func (rw *ResponseWriter) Status() int {
+	return int(rw.response.code)
+}
+
+// BytesWritten returns the length of the response body, before any Content-Encoding is applied. For a body streamed with
+// BodyWriter, it is the number of bytes written so far.
+func (rw *ResponseWriter) BytesWritten() int {
+	if rw.stream != nil {
+		return rw.stream.written
+	}
+
+	return len(rw.response.body)
+}
+
+// Error responds with code and a plain text body of message, followed by a newline. Codes StatusText does not recognize
+// are refused, leaving the response unchanged.
+func (rw *ResponseWriter) Error(code int, message string) error {
+	err := rw.SetStatus(code)
+	if err != nil {
+		return err
+	}
+
+	rw.response.headers.contentType = ContentType{Type: "text", Subtype: "plain"}
+	rw.contentTypeSet = true
+	rw.SetBody([]byte(message + "\n"))
+	return nil
+}
+
+// JSON responds with code and v encoded as JSON. If v cannot be encoded, its error is returned and the response is left
+// unchanged.
+func (rw *ResponseWriter) JSON(code int, v any) error {
+	if StatusText(code) == "" {
+		return fmt.Errorf("not a valid status code")
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	rw.SetStatus(code)
+	rw.response.headers.contentType = ContentType{Type: "application", Subtype: "json"}
+	rw.contentTypeSet = true
+	rw.SetBody(body)
+	return nil
+}
+
+// BodyWriter sends the status line and headers set so far, and returns a writer that streams the body to the client
+// using the Content-Encoding set with SetContentEncoding. No Content-Length is sent, so the connection is closed once the
+// handler calls Close; when Server.AllowChunkedResponses is set and the request is HTTP/1.1 or later, the body is also
+// framed with the chunked transfer coding. Anything set with SetBody, or any header set after the first call, is ignored.
+func (rw *ResponseWriter) BodyWriter() io.WriteCloser {
+	if rw.stream != nil {
+		return rw.stream
+	}
+
+	rw.stream = &bodyWriter{}
+	if rw.conn == nil {
+		rw.stream.err = fmt.Errorf("response writer is not attached to a connection")
+		return rw.stream
+	}
+
+	rw.written = true
+	rw.stream.deadliner, _ = rw.conn.(writeDeadliner)
+	rw.stream.timeout = rw.writeTimeout
+	chunked := rw.chunked && acceptsTransferCodings(rw.version) && rw.response.code.allowsBody()
+	if chunked {
+		rw.response.headers.transferEncoding = "chunked"
+	}
+
+	rw.response.headers.connection = ConnectionOptions{"close"}
+	rw.response.headers.setDefaultServer(rw.defaultServer)
+	if rw.autoDate {
+		rw.response.headers.setDefaultDate()
+	}
+	// a client only honors Transfer-Encoding on an HTTP/1.1 message, so a chunked body needs an HTTP/1.1 status line
+	line := rw.response.code.marshal()
+	if chunked {
+		line = rw.response.code.marshalVersion("1.1")
+	}
+	marshaled := append(line, rw.response.headers.marshal(false)...)
+	rw.stream.setWriteDeadline()
+	_, err := rw.conn.Write(marshaled)
+	if err != nil {
+		rw.stream.err = err
+		return rw.stream
+	}
+
+	w := rw.conn
+	if rw.discardBody || !rw.response.code.allowsBody() {
+		w = io.Discard
+	} else if chunked {
+		cw := &chunkedWriter{w: w}
+		rw.stream.framing = cw
+		w = cw
+	}
+
+	rw.stream.w = newEncodingWriter(w, rw.response.headers.contentEncoding)
+	return rw.stream
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// bodyWriter streams a body to the connection. Each write to the connection gets its own deadline of timeout, so a client
+// that stops reading cannot hold the handler forever.
+type bodyWriter struct {
+	w         io.WriteCloser
+	framing   io.Closer
+	deadliner writeDeadliner
+	timeout   time.Duration
+	err       error
+	closed    bool
+	written   int
+}
+
+func (bw *bodyWriter) setWriteDeadline() {
+	if bw.deadliner == nil || bw.timeout == 0 {
+		return
+	}
+
+	bw.deadliner.SetWriteDeadline(time.Now().Add(bw.timeout))
+}
+
+func (bw *bodyWriter) Write(p []byte) (int, error) {
+	if bw.err != nil {
+		return 0, bw.err
+	}
+	if bw.closed {
+		return 0, fmt.Errorf("body writer is closed")
+	}
+
+	bw.setWriteDeadline()
+	n, err := bw.w.Write(p)
+	bw.written += n
+	if err != nil {
+		bw.err = err
+		return n, err
+	}
+
+	if f, ok := bw.w.(interface{ Flush() error }); ok {
+		bw.err = f.Flush()
+	}
+
+	return n, bw.err
+}
+
+func (bw *bodyWriter) Close() error {
+	if bw.closed || bw.err != nil {
+		return bw.err
+	}
+
+	bw.closed = true
+	bw.setWriteDeadline()
+	err := bw.w.Close()
+	if err != nil || bw.framing == nil {
+		return err
+	}
+
+	return bw.framing.Close()
+}
+
+// acceptsTransferCodings reports whether a client that sent a request with version, such as "1.1", understands
+// transfer-codings. HTTP/1.0 clients do not, and must never be sent one.
+func acceptsTransferCodings(version string) bool {
+	major, minor, ok := strings.Cut(version, ".")
+	if !ok {
+		return false
+	}
+
+	m, err1 := strconv.Atoi(major)
+	n, err2 := strconv.Atoi(minor)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	return m > 1 || (m == 1 && n >= 1)
+}
+
+// chunkedWriter frames each write as a chunk of the chunked transfer coding. Close writes the terminating zero-size
+// chunk.
+type chunkedWriter struct {
+	w io.Writer
+}
+
+func (cw *chunkedWriter) Write(p []byte) (int, error) {
+	// a zero-size chunk would end the body early
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	chunk := fmt.Appendf(nil, "%x%s", len(p), constructs.Crlf)
+	chunk = append(chunk, p...)
+	chunk = append(chunk, constructs.Crlf...)
+
+	_, err := cw.w.Write(chunk)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (cw *chunkedWriter) Close() error {
+	_, err := cw.w.Write([]byte("0" + constructs.Crlf + constructs.Crlf))
+	return err
+}
+
 func prepareTime(t time.Time) time.Time {
 	return t.In(time.FixedZone("GMT", 0))
 }
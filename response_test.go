@@ -1,7 +1,18 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tony-montemuro/http/internal/assert"
 )
@@ -42,3 +53,1239 @@ func TestSetStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseWriter_Bytes(t *testing.T) {
+	date := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		status   int
+		head     bool
+		expected string
+	}{
+		{
+			name:     "Response with body",
+			status:   StatusOK,
+			expected: "HTTP/1.0 200 OK\r\nDate: Sun, 06 Nov 1994 08:49:37 GMT\r\nServer: tony/1.0\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\nhello",
+		},
+		{
+			name:     "HEAD request",
+			status:   StatusOK,
+			head:     true,
+			expected: "HTTP/1.0 200 OK\r\nDate: Sun, 06 Nov 1994 08:49:37 GMT\r\nServer: tony/1.0\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\n",
+		},
+		{
+			name:     "Status without a body",
+			status:   StatusNoContent,
+			expected: "HTTP/1.0 204 No Content\r\nDate: Sun, 06 Nov 1994 08:49:37 GMT\r\nServer: tony/1.0\r\nContent-Type: text/plain\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse(), discardBody: tt.head, defaultServer: ProductVersion{Product: "tony", Version: "1.0"}}
+			rw.SetDateHeader(date)
+			rw.SetContentTypeHeader([]byte("text"), []byte("plain"))
+			rw.SetBody([]byte("hello"))
+
+			err := rw.SetStatus(tt.status)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			assert.Equal(t, string(rw.Bytes()), tt.expected)
+			assert.Equal(t, len(rw.response.headers.server.products), 0)
+		})
+	}
+}
+
+func TestResponseWriter_AddContentTypeHeaderParameter(t *testing.T) {
+	tests := []struct {
+		name        string
+		param       string
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{"Token value", "charset", "utf-8", "text/plain;charset=utf-8", false},
+		{"Quoted value", "title", "hello world", `text/plain;title="hello world"`, false},
+		{"Already quoted value", "title", `"hello"`, `text/plain;title="hello"`, false},
+		{"Empty value", "charset", "", "", true},
+		{"Empty quoted value", "charset", `""`, "", true},
+		{"Empty name", "", "utf-8", "", true},
+		{"Malformed name", "char set", "utf-8", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+			rw.SetContentTypeHeader([]byte("text"), []byte("plain"))
+
+			err := rw.AddContentTypeHeaderParameter([]byte(tt.param), []byte(tt.value))
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				assert.Equal(t, len(rw.response.headers.contentType.Parameters), 0)
+				return
+			}
+
+			assert.Equal(t, string(rw.response.headers.contentType.marshal()), tt.expected)
+		})
+	}
+}
+
+func TestResponseWriter_zeroValue(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(rw *ResponseWriter) error
+	}{
+		{"SetNoCache", func(rw *ResponseWriter) error {
+			rw.SetNoCache(true)
+			return nil
+		}},
+		{"AddPragmaHeader", func(rw *ResponseWriter) error {
+			return rw.AddPragmaHeader([]byte("foo"), []byte("bar"))
+		}},
+		{"SetChallenge", func(rw *ResponseWriter) error {
+			return rw.SetChallenge([]byte("Basic"), []byte("site"))
+		}},
+		{"AddChallengeParameter", func(rw *ResponseWriter) error {
+			rw.response.headers.wwwAuthenticate = []challenge{{scheme: "Basic", realm: `"site"`}}
+			return rw.AddChallengeParameter([]byte("charset"), []byte("UTF-8"))
+		}},
+		{"SetHeader", func(rw *ResponseWriter) error {
+			return rw.SetHeader([]byte("X-Foo"), []byte("bar"))
+		}},
+		{"AddContentTypeHeaderParameter", func(rw *ResponseWriter) error {
+			return rw.AddContentTypeHeaderParameter([]byte("charset"), []byte("utf-8"))
+		}},
+		{"SetCacheControl", func(rw *ResponseWriter) error {
+			return rw.SetCacheControl("no-store")
+		}},
+		{"AddAllowHeader", func(rw *ResponseWriter) error {
+			rw.AddAllowHeader([]byte("GET"))
+			return nil
+		}},
+		{"AddServerHeader", func(rw *ResponseWriter) error {
+			return rw.AddServerHeader([]byte("tony/1.0"))
+		}},
+		{"SetCookie", func(rw *ResponseWriter) error {
+			return rw.SetCookie(Cookie{Name: "id", Value: "1"})
+		}},
+		{"Error", func(rw *ResponseWriter) error {
+			return rw.Error(StatusNotFound, "missing")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if v := recover(); v != nil {
+					t.Errorf("%s panicked on a zero ResponseWriter: %v", tt.name, v)
+				}
+			}()
+
+			rw := ResponseWriter{}
+			err := tt.set(&rw)
+			if err != nil {
+				t.Errorf("got unexpected error: %s", err.Error())
+			}
+			rw.Bytes()
+		})
+	}
+}
+
+func TestResponseWriter_MethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []Method
+		allowed  []Method
+		expected string
+	}{
+		{"Sorted", nil, []Method{MethodPost, MethodGet, MethodHead}, "Allow: GET, HEAD, POST\r\n"},
+		{"Duplicates", nil, []Method{MethodGet, MethodHead, MethodGet, MethodHead}, "Allow: GET, HEAD\r\n"},
+		{"Replaces existing", []Method{MethodOptions}, []Method{MethodGet}, "Allow: GET\r\n"},
+		{"No methods", nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+			for _, m := range tt.existing {
+				rw.AddAllowHeader([]byte(m))
+			}
+
+			err := rw.MethodNotAllowed(tt.allowed...)
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err.Error())
+			}
+
+			res := string(rw.Bytes())
+			assert.Equal(t, strings.HasPrefix(res, "HTTP/1.0 405 Method Not Allowed\r\n"), true)
+			_, allow, _ := strings.Cut(res, "Allow:")
+			if tt.expected == "" {
+				assert.Equal(t, allow, "")
+				return
+			}
+			line, _, _ := strings.Cut(allow, "\r\n")
+			assert.Equal(t, "Allow:"+line+"\r\n", tt.expected)
+		})
+	}
+}
+
+func TestResponseWriter_AddAllowHeader(t *testing.T) {
+	rw := ResponseWriter{response: getDefaultResponse()}
+	rw.AddAllowHeader([]byte("GET"))
+	rw.AddAllowHeader([]byte("POST"))
+	rw.AddAllowHeader([]byte("GET"))
+
+	assert.SliceEqual(t, rw.response.headers.allow.methods, []Method{MethodGet, MethodPost})
+}
+
+func TestResponseWriter_Written(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(rw *ResponseWriter)
+	}{
+		{
+			name:  "Body writer sent headers",
+			write: func(rw *ResponseWriter) { rw.BodyWriter() },
+		},
+		{
+			name:  "Server sent response",
+			write: func(rw *ResponseWriter) { rw.written = true },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conn bytes.Buffer
+			rw := ResponseWriter{response: getDefaultResponse(), conn: &conn}
+			assert.Equal(t, rw.Written(), false)
+
+			tt.write(&rw)
+			assert.Equal(t, rw.Written(), true)
+
+			err := rw.SetStatus(StatusNotFound)
+			assert.Equal(t, errors.Is(err, ErrResponseWritten), true)
+			assert.Equal(t, rw.Status(), StatusOK)
+
+			err = rw.SetHeader([]byte("X-Late"), []byte("1"))
+			assert.Equal(t, errors.Is(err, ErrResponseWritten), true)
+			assert.Equal(t, len(rw.response.headers.unrecognized), 0)
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name             string
+		uri              []byte
+		temporary        bool
+		expectedCode     code
+		expectedLocation []byte
+		expectError      bool
+	}{
+		{
+			name:             "Absolute redirect",
+			uri:              []byte("http://example.com/new"),
+			expectedCode:     StatusMovedPermanently,
+			expectedLocation: []byte("http://example.com/new"),
+			expectError:      false,
+		},
+		{
+			name:             "Relative redirect",
+			uri:              []byte("/new/home?a=1"),
+			temporary:        true,
+			expectedCode:     StatusMovedTemporarily,
+			expectedLocation: []byte("/new/home?a=1"),
+			expectError:      false,
+		},
+		{
+			name:             "Relative path redirect",
+			uri:              []byte("sibling"),
+			expectedCode:     StatusMovedPermanently,
+			expectedLocation: []byte("sibling"),
+			expectError:      false,
+		},
+		{
+			name:         "Malformed relative target",
+			uri:          []byte("/new home"),
+			expectedCode: StatusOK,
+			expectError:  true,
+		},
+		{
+			name:         "Malformed absolute target",
+			uri:          []byte("http://example.com/<new>"),
+			expectedCode: StatusOK,
+			expectError:  true,
+		},
+		{
+			name:         "Empty target",
+			uri:          []byte(""),
+			expectedCode: StatusOK,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+
+			var err error
+			if tt.temporary {
+				err = rw.RedirectTemporary(tt.uri)
+			} else {
+				err = rw.Redirect(tt.uri)
+			}
+
+			assert.Equal(t, rw.response.code, tt.expectedCode)
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				assert.Equal(t, rw.response.headers.location, nil)
+				return
+			}
+
+			assert.SliceEqual(t, rw.response.headers.location.marshalEncoded(), tt.expectedLocation)
+		})
+	}
+}
+
+func TestRedirectSeeOther(t *testing.T) {
+	rw := ResponseWriter{response: getDefaultResponse()}
+
+	err := rw.RedirectSeeOther([]byte("/orders/42"))
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	head := string(rw.response.marshalHead())
+	assert.Equal(t, strings.HasPrefix(head, "HTTP/1.0 303 See Other\r\n"), true)
+	assert.Equal(t, strings.Contains(head, "\r\nLocation: /orders/42\r\n"), true)
+}
+
+func TestPreconditionFailedIfModified(t *testing.T) {
+	since := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0))
+
+	tests := []struct {
+		name              string
+		lastModified      time.Time
+		ifUnmodifiedSince time.Time
+		expected          bool
+		expectedCode      code
+	}{
+		{
+			name:              "Modified",
+			lastModified:      since.Add(time.Second),
+			ifUnmodifiedSince: since,
+			expected:          true,
+			expectedCode:      StatusPreconditionFailed,
+		},
+		{
+			name:              "Unmodified",
+			lastModified:      since.Add(-time.Hour),
+			ifUnmodifiedSince: since,
+			expected:          false,
+			expectedCode:      StatusOK,
+		},
+		{
+			name:              "Same second in another time zone",
+			lastModified:      since.Add(500 * time.Millisecond).In(time.FixedZone("EST", -5*60*60)),
+			ifUnmodifiedSince: since,
+			expected:          false,
+			expectedCode:      StatusOK,
+		},
+		{
+			name:         "Missing header",
+			lastModified: since,
+			expected:     false,
+			expectedCode: StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Headers: RequestHeaders{IfUnmodifiedSince: MessageTime{tt.ifUnmodifiedSince}}}
+			rw := ResponseWriter{response: getDefaultResponse()}
+			rw.SetBody([]byte("hello"))
+
+			res := rw.PreconditionFailedIfModified(tt.lastModified, r)
+
+			assert.Equal(t, res, tt.expected)
+			assert.Equal(t, rw.response.code, tt.expectedCode)
+			if tt.expected {
+				assert.Equal(t, string(rw.response.body), "Precondition Failed")
+			} else {
+				assert.Equal(t, string(rw.response.body), "hello")
+			}
+		})
+	}
+}
+
+func TestResponseWriter_ServeContent(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0))
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name                string
+		fileName            string
+		content             []byte
+		contentType         *ContentType
+		ifModifiedSince     time.Time
+		expectedCode        code
+		expectedContentType ContentType
+		expectedBody        []byte
+	}{
+		{
+			name:                "PNG magic bytes",
+			fileName:            "logo",
+			content:             png,
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "image", Subtype: "png"},
+			expectedBody:        png,
+		},
+		{
+			name:                "JPEG magic bytes",
+			fileName:            "photo",
+			content:             []byte("\xff\xd8\xff\xe0\x00\x10JFIF"),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "image", Subtype: "jpeg"},
+			expectedBody:        []byte("\xff\xd8\xff\xe0\x00\x10JFIF"),
+		},
+		{
+			name:                "GIF magic bytes",
+			fileName:            "anim",
+			content:             []byte("GIF89a\x01\x00"),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "image", Subtype: "gif"},
+			expectedBody:        []byte("GIF89a\x01\x00"),
+		},
+		{
+			name:                "HTML document",
+			fileName:            "index",
+			content:             []byte("\n  <!DOCTYPE html><html><body>hi</body></html>"),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "text", Subtype: "html", Parameters: map[string]string{"charset": "utf-8"}},
+			expectedBody:        []byte("\n  <!DOCTYPE html><html><body>hi</body></html>"),
+		},
+		{
+			name:                "Plain text",
+			fileName:            "notes",
+			content:             []byte("<pre> is not html\n"),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "text", Subtype: "plain", Parameters: map[string]string{"charset": "utf-8"}},
+			expectedBody:        []byte("<pre> is not html\n"),
+		},
+		{
+			name:                "Binary data",
+			fileName:            "blob",
+			content:             []byte{0x00, 0x01, 0x02},
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "application", Subtype: "octet-stream"},
+			expectedBody:        []byte{0x00, 0x01, 0x02},
+		},
+		{
+			name:                "Known extension",
+			fileName:            "site.CSS",
+			content:             []byte("body { color: red; }"),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "text", Subtype: "css", Parameters: map[string]string{"charset": "utf-8"}},
+			expectedBody:        []byte("body { color: red; }"),
+		},
+		{
+			name:                "Unknown extension",
+			fileName:            "logo.unknown",
+			content:             png,
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "image", Subtype: "png"},
+			expectedBody:        png,
+		},
+		{
+			name:                "Content-Type already set",
+			fileName:            "logo",
+			content:             png,
+			contentType:         &ContentType{Type: "image", Subtype: "x-custom"},
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "image", Subtype: "x-custom"},
+			expectedBody:        png,
+		},
+		{
+			name:                "Default Content-Type set explicitly",
+			fileName:            "index.html",
+			content:             []byte("<html></html>"),
+			contentType:         &ContentType{Type: "application", Subtype: "octet-stream"},
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "application", Subtype: "octet-stream"},
+			expectedBody:        []byte("<html></html>"),
+		},
+		{
+			name:                "Not modified",
+			fileName:            "index",
+			content:             []byte("<html></html>"),
+			ifModifiedSince:     modTime,
+			expectedCode:        StatusNotModified,
+			expectedContentType: ContentType{Type: "text", Subtype: "html", Parameters: map[string]string{"charset": "utf-8"}},
+			expectedBody:        []byte{},
+		},
+		{
+			name:                "Modified",
+			fileName:            "index",
+			content:             []byte("<html></html>"),
+			ifModifiedSince:     modTime.Add(-time.Hour),
+			expectedCode:        StatusOK,
+			expectedContentType: ContentType{Type: "text", Subtype: "html", Parameters: map[string]string{"charset": "utf-8"}},
+			expectedBody:        []byte("<html></html>"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Headers: RequestHeaders{IfModifiedSince: MessageTime{tt.ifModifiedSince}}}
+			rw := ResponseWriter{response: getDefaultResponse()}
+			if tt.contentType != nil {
+				err := rw.SetContentTypeHeader([]byte(tt.contentType.Type), []byte(tt.contentType.Subtype))
+				if err != nil {
+					t.Fatalf("Test could not complete! (%s)", err.Error())
+				}
+			}
+
+			rw.ServeContent(tt.fileName, modTime, tt.content, r)
+
+			assert.Equal(t, rw.response.code, tt.expectedCode)
+			assert.Equal(t, rw.response.headers.contentType.Type, tt.expectedContentType.Type)
+			assert.Equal(t, rw.response.headers.contentType.Subtype, tt.expectedContentType.Subtype)
+			assert.MapEqual(t, rw.response.headers.contentType.Parameters, tt.expectedContentType.Parameters)
+			assert.DateEqual(t, rw.response.headers.lastModified.date, modTime)
+			assert.SliceEqual(t, rw.response.body, tt.expectedBody)
+		})
+	}
+}
+
+func TestResponseWriter_ServeFile(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "public")
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	files := map[string]string{
+		filepath.Join(root, "docs", "readme.txt"): "hello, world\n",
+		filepath.Join(dir, "secret.txt"):          "top secret\n",
+	}
+	for path, content := range files {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0o644)
+		}
+		if err == nil {
+			err = os.Chtimes(path, modTime, modTime)
+		}
+		if err != nil {
+			t.Fatalf("Test could not complete! (%s)", err.Error())
+		}
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedBody   string
+		expectedStatus int
+	}{
+		{
+			name:         "Existing file",
+			path:         "/docs/readme.txt",
+			expectedBody: "hello, world\n",
+		},
+		{
+			name:         "Path with dot segments inside root",
+			path:         "/docs/../docs/./readme.txt",
+			expectedBody: "hello, world\n",
+		},
+		{
+			name:           "Missing file",
+			path:           "/docs/missing.txt",
+			expectedStatus: StatusNotFound,
+		},
+		{
+			name:           "Directory",
+			path:           "/docs",
+			expectedStatus: StatusNotFound,
+		},
+		{
+			name:           "Traversal outside root",
+			path:           "/../secret.txt",
+			expectedStatus: StatusForbidden,
+		},
+		{
+			name:           "Sibling with root as prefix",
+			path:           "/../public-other/readme.txt",
+			expectedStatus: StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+
+			err := rw.ServeFile(root, tt.path, Request{})
+			ok := assert.ErrorStatus(t, err, tt.expectedStatus != 0)
+			if !ok {
+				var clientErr ClientError
+				if !errors.As(err, &clientErr) {
+					t.Errorf("expected ClientError, got %T", err)
+					return
+				}
+
+				assert.Equal(t, clientErr.Status(), tt.expectedStatus)
+				assert.Equal(t, len(rw.response.body), 0)
+				return
+			}
+
+			assert.Equal(t, string(rw.response.body), tt.expectedBody)
+			assert.Equal(t, rw.response.headers.contentLength, ContentLength(len(tt.expectedBody)))
+			assert.Equal(t, rw.response.headers.contentType.Type, "text")
+			assert.DateEqual(t, rw.response.headers.lastModified.date, modTime)
+		})
+	}
+}
+
+func TestNotModifiedIfUnchanged(t *testing.T) {
+	since := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("GMT", 0))
+
+	tests := []struct {
+		name            string
+		lastModified    time.Time
+		ifModifiedSince time.Time
+		expected        bool
+		expectedCode    code
+	}{
+		{
+			name:            "Unchanged",
+			lastModified:    since.Add(-time.Hour),
+			ifModifiedSince: since,
+			expected:        true,
+			expectedCode:    StatusNotModified,
+		},
+		{
+			name:            "Same second",
+			lastModified:    since.Add(500 * time.Millisecond),
+			ifModifiedSince: since,
+			expected:        true,
+			expectedCode:    StatusNotModified,
+		},
+		{
+			name:            "Same instant in another time zone",
+			lastModified:    since.In(time.FixedZone("EST", -5*60*60)),
+			ifModifiedSince: since,
+			expected:        true,
+			expectedCode:    StatusNotModified,
+		},
+		{
+			name:            "Changed",
+			lastModified:    since.Add(time.Second),
+			ifModifiedSince: since,
+			expected:        false,
+			expectedCode:    StatusOK,
+		},
+		{
+			name:         "Missing header",
+			lastModified: since,
+			expected:     false,
+			expectedCode: StatusOK,
+		},
+		{
+			name:            "Future If-Modified-Since",
+			lastModified:    since,
+			ifModifiedSince: time.Now().Add(time.Hour),
+			expected:        false,
+			expectedCode:    StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Headers: RequestHeaders{IfModifiedSince: MessageTime{tt.ifModifiedSince}}}
+			rw := ResponseWriter{response: getDefaultResponse()}
+			rw.SetBody([]byte("hello"))
+
+			res := rw.NotModifiedIfUnchanged(tt.lastModified, r)
+
+			assert.Equal(t, res, tt.expected)
+			assert.Equal(t, rw.response.code, tt.expectedCode)
+			if tt.expected {
+				assert.Equal(t, len(rw.response.body), 0)
+				assert.Equal(t, rw.response.headers.contentLength, ContentLength(0))
+			} else {
+				assert.Equal(t, string(rw.response.body), "hello")
+			}
+		})
+	}
+}
+
+func TestSetCacheControl(t *testing.T) {
+	tests := []struct {
+		name        string
+		directives  []string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:        "Single directive",
+			directives:  []string{"no-store"},
+			expected:    "no-store",
+			expectError: false,
+		},
+		{
+			name:        "Duplicate directives collapse",
+			directives:  []string{"max-age=10", "no-cache", "max-age=60", "no-cache"},
+			expected:    "no-cache, max-age=60",
+			expectError: false,
+		},
+		{
+			name:        "Bad max-age",
+			directives:  []string{"no-cache", "max-age=1.5"},
+			expectError: true,
+		},
+		{
+			name:        "Bad directive",
+			directives:  []string{"no cache"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{}
+			err := rw.SetCacheControl(tt.directives...)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, string(rw.response.headers.cacheControl.marshal()), tt.expected)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        int
+		message     string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:    "Not found",
+			code:    404,
+			message: "no such page",
+			expected: "HTTP/1.0 404 Not Found\r\n" +
+				"Content-Length: 13\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"\r\n" +
+				"no such page\n",
+			expectError: false,
+		},
+		{
+			name:    "Internal server error",
+			code:    500,
+			message: "something broke",
+			expected: "HTTP/1.0 500 Internal Server Error\r\n" +
+				"Content-Length: 16\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"\r\n" +
+				"something broke\n",
+			expectError: false,
+		},
+		{
+			name:        "Unknown code",
+			code:        299,
+			message:     "unknown",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: response{code: StatusOK}}
+
+			err := rw.Error(tt.code, tt.message)
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				assert.Equal(t, rw.response.code, StatusOK)
+				assert.Equal(t, len(rw.response.body), 0)
+				return
+			}
+
+			assert.Equal(t, string(rw.response.marshal()), tt.expected)
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	tests := []struct {
+		name        string
+		code        int
+		value       any
+		expected    string
+		expectError bool
+	}{
+		{
+			name:        "Struct",
+			code:        200,
+			value:       user{Name: "Tony", Age: 30},
+			expected:    `{"name":"Tony","age":30}`,
+			expectError: false,
+		},
+		{
+			name:        "Map",
+			code:        201,
+			value:       map[string]int{"b": 2, "a": 1},
+			expected:    `{"a":1,"b":2}`,
+			expectError: false,
+		},
+		{
+			name:        "Channel",
+			code:        200,
+			value:       make(chan int),
+			expectError: true,
+		},
+		{
+			name:        "Unknown code",
+			code:        299,
+			value:       user{},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+			rw.response.code = StatusAccepted
+
+			err := rw.JSON(tt.code, tt.value)
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				assert.Equal(t, rw.response.code, StatusAccepted)
+				assert.Equal(t, rw.response.headers.contentType.Subtype, "octet-stream")
+				assert.Equal(t, len(rw.response.body), 0)
+				return
+			}
+
+			assert.Equal(t, int(rw.response.code), tt.code)
+			assert.Equal(t, string(rw.response.headers.contentType.marshal()), "application/json")
+			assert.Equal(t, string(rw.response.body), tt.expected)
+			assert.Equal(t, rw.response.headers.contentLength, ContentLength(len(tt.expected)))
+		})
+	}
+}
+
+func TestRequireBasicAuth(t *testing.T) {
+	check := func(user, pass string) bool {
+		userOk := subtle.ConstantTimeCompare([]byte(user), []byte("tony")) == 1
+		passOk := subtle.ConstantTimeCompare([]byte(pass), []byte("secret")) == 1
+		return userOk && passOk
+	}
+
+	tests := []struct {
+		name        string
+		credentials AuthorizationCredentials
+		expected    bool
+	}{
+		{
+			name:        "Missing credentials",
+			credentials: AuthorizationCredentials{},
+			expected:    false,
+		},
+		{
+			name:        "Wrong password",
+			credentials: AuthorizationCredentials{Scheme: "Basic", Parameters: map[string]string{"userid": "tony", "password": "guess"}},
+			expected:    false,
+		},
+		{
+			name:        "Correct password",
+			credentials: AuthorizationCredentials{Scheme: "Basic", Parameters: map[string]string{"userid": "tony", "password": "secret"}},
+			expected:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{response: getDefaultResponse()}
+			req := Request{Headers: RequestHeaders{Authorization: tt.credentials}}
+
+			res := rw.RequireBasicAuth(req, "admin", check)
+			assert.Equal(t, res, tt.expected)
+
+			if tt.expected {
+				assert.Equal(t, rw.response.code, StatusOK)
+				assert.Equal(t, len(rw.response.headers.wwwAuthenticate), 0)
+			} else {
+				assert.Equal(t, rw.response.code, StatusUnauthorized)
+				assert.Equal(t, len(rw.response.headers.wwwAuthenticate), 1)
+				assert.Equal(t, string(rw.response.headers.wwwAuthenticate[0].marshal()), `Basic realm="admin"`)
+			}
+		})
+	}
+}
+
+func TestSetChallenge_multiple(t *testing.T) {
+	rw := ResponseWriter{response: getDefaultResponse()}
+
+	err := rw.AddChallengeParameter([]byte("nonce"), []byte("abc"))
+	assert.ErrorStatus(t, err, true)
+
+	err = rw.SetChallenge([]byte("Basic"), []byte("admin"))
+	if err == nil {
+		err = rw.AddChallenge([]byte("Digest"), []byte("admin"))
+	}
+	if err == nil {
+		err = rw.AddChallengeParameter([]byte("nonce"), []byte("abc"))
+	}
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	headers := string(rw.response.headers.marshal(false))
+	assert.Equal(t, strings.Count(headers, "WWW-Authenticate: "), 2)
+	assert.Equal(t, strings.Contains(headers, "WWW-Authenticate: Basic realm=\"admin\"\r\n"), true)
+	assert.Equal(t, strings.Contains(headers, "WWW-Authenticate: Digest realm=\"admin\",nonce=\"abc\"\r\n"), true)
+}
+
+func TestSetMimeVersion(t *testing.T) {
+	rw := ResponseWriter{response: getDefaultResponse()}
+
+	err := rw.SetMimeVersion(1, 0)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+	assert.Equal(t, string(marshalHeader("MIME-Version", rw.response.headers.mimeVersion)), "MIME-Version: 1.0\r\n")
+
+	err = rw.SetMimeVersion(-1, 0)
+	assert.ErrorStatus(t, err, true)
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       time.Duration
+		expected    string
+		expectError bool
+	}{
+		{
+			name:        "Whole seconds",
+			value:       2 * time.Minute,
+			expected:    "120",
+			expectError: false,
+		},
+		{
+			name:        "Partial seconds round up",
+			value:       1500 * time.Millisecond,
+			expected:    "2",
+			expectError: false,
+		},
+		{
+			name:        "Zero",
+			value:       0,
+			expected:    "0",
+			expectError: false,
+		},
+		{
+			name:        "Negative",
+			value:       -time.Second,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{}
+			err := rw.SetRetryAfter(tt.value)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, string(rw.response.headers.retryAfter.marshal()), tt.expected)
+		})
+	}
+}
+
+func TestSetRetryAfterDate(t *testing.T) {
+	rw := ResponseWriter{}
+	rw.SetRetryAfterDate(time.Date(2024, 1, 2, 10, 4, 5, 0, time.FixedZone("EST", -5*60*60)))
+
+	assert.Equal(t, string(rw.response.headers.retryAfter.marshal()), "Tue, 02 Jan 2024 15:04:05 GMT")
+}
+
+func TestBodyWriter(t *testing.T) {
+	body := bytes.Repeat([]byte("streamed body data "), 1<<20/19+1)[:1<<20]
+
+	tests := []struct {
+		name     string
+		encoding ContentEncoding
+		head     bool
+	}{
+		{
+			name:     "Gzip",
+			encoding: ContentEncodingGZip,
+		},
+		{
+			name:     "Compress",
+			encoding: ContentEncodingXCompress,
+		},
+		{
+			name:     "Deflate",
+			encoding: ContentEncodingDeflate,
+		},
+		{
+			name: "No encoding",
+		},
+		{
+			name:     "HEAD request",
+			encoding: ContentEncodingGZip,
+			head:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conn bytes.Buffer
+			rw := ResponseWriter{response: getDefaultResponse(), conn: &conn, discardBody: tt.head}
+			if len(tt.encoding) > 0 {
+				rw.SetContentEncoding([]byte(tt.encoding))
+			}
+
+			w := rw.BodyWriter()
+			for chunk := range slices.Chunk(body, 4096) {
+				_, err := w.Write(chunk)
+				if err != nil {
+					t.Fatalf("got unexpected error: %s", err.Error())
+				}
+			}
+
+			err := w.Close()
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err.Error())
+			}
+
+			reader := bufio.NewReader(&conn)
+			headers := []string{}
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					t.Fatalf("Test could not complete! (%s)", err.Error())
+				}
+				if line == "\r\n" {
+					break
+				}
+				headers = append(headers, line)
+			}
+
+			assert.Equal(t, headers[0], "HTTP/1.0 200 OK\r\n")
+			for _, header := range headers {
+				assert.Equal(t, strings.HasPrefix(header, "Content-Length"), false)
+			}
+
+			rest := mustReadAll(t, reader)
+			if tt.head {
+				assert.Equal(t, len(rest), 0)
+				return
+			}
+
+			decoded, err := decodeRequestBody(rest, 0, tt.encoding)
+			if err != nil {
+				t.Fatalf("could not decode streamed body: %s", err.Error())
+			}
+
+			assert.Equal(t, bytes.Equal(decoded, body), true)
+		})
+	}
+}
+
+func TestBodyWriter_gzipIsStreamed(t *testing.T) {
+	var conn bytes.Buffer
+	rw := ResponseWriter{response: getDefaultResponse(), conn: &conn}
+	rw.SetContentEncoding([]byte("gzip"))
+
+	w := rw.BodyWriter()
+	w.Write([]byte("first chunk"))
+	written := conn.Len()
+
+	_, body, _ := bytes.Cut(conn.Bytes(), []byte("\r\n\r\n"))
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	chunk := make([]byte, len("first chunk"))
+	_, err = io.ReadFull(reader, chunk)
+	if err != nil {
+		t.Fatalf("first chunk was not flushed: %s", err.Error())
+	}
+	assert.Equal(t, string(chunk), "first chunk")
+
+	w.Close()
+	assert.Equal(t, conn.Len() > written, true)
+}
+
+func mustReadAll(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	return data
+}
+
+func TestSetCookie(t *testing.T) {
+	tests := []struct {
+		name        string
+		cookie      Cookie
+		expectError bool
+	}{
+		{
+			name:        "Minimal cookie",
+			cookie:      Cookie{Name: "session", Value: "abc123"},
+			expectError: false,
+		},
+		{
+			name:        "Cookie with attributes",
+			cookie:      Cookie{Name: "session", Value: "abc123", Path: "/app", Domain: "example.com", Secure: true},
+			expectError: false,
+		},
+		{
+			name:        "Invalid name",
+			cookie:      Cookie{Name: "bad name", Value: "abc123"},
+			expectError: true,
+		},
+		{
+			name:        "Empty name",
+			cookie:      Cookie{Value: "abc123"},
+			expectError: true,
+		},
+		{
+			name:        "Invalid value",
+			cookie:      Cookie{Name: "session", Value: "a;b"},
+			expectError: true,
+		},
+		{
+			name:        "Invalid path",
+			cookie:      Cookie{Name: "session", Value: "abc123", Path: "/a;Secure"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := ResponseWriter{}
+			err := rw.SetCookie(tt.cookie)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, len(rw.response.headers.setCookies), 1)
+			assert.Equal(t, rw.response.headers.setCookies[0].Name, tt.cookie.Name)
+		})
+	}
+}
+
+func TestServePartial(t *testing.T) {
+	full := []byte("0123456789")
+
+	tests := []struct {
+		name         string
+		byteRange    ByteRange
+		expected     bool
+		expectedCode code
+		contentRange string
+		body         string
+	}{
+		{
+			name:         "Closed range",
+			byteRange:    ByteRange{Unit: "bytes", Start: 2, End: 5},
+			expected:     true,
+			expectedCode: StatusPartialContent,
+			contentRange: "bytes 2-5/10",
+			body:         "2345",
+		},
+		{
+			name:         "Open-ended range",
+			byteRange:    ByteRange{Unit: "bytes", Start: 7, End: -1},
+			expected:     true,
+			expectedCode: StatusPartialContent,
+			contentRange: "bytes 7-9/10",
+			body:         "789",
+		},
+		{
+			name:         "Suffix range",
+			byteRange:    ByteRange{Unit: "bytes", Start: -1, End: -1, SuffixLength: 3},
+			expected:     true,
+			expectedCode: StatusPartialContent,
+			contentRange: "bytes 7-9/10",
+			body:         "789",
+		},
+		{
+			name:         "Suffix range longer than entity",
+			byteRange:    ByteRange{Unit: "bytes", Start: -1, End: -1, SuffixLength: 50},
+			expected:     true,
+			expectedCode: StatusPartialContent,
+			contentRange: "bytes 0-9/10",
+			body:         "0123456789",
+		},
+		{
+			name:         "End past entity is truncated",
+			byteRange:    ByteRange{Unit: "bytes", Start: 8, End: 100},
+			expected:     true,
+			expectedCode: StatusPartialContent,
+			contentRange: "bytes 8-9/10",
+			body:         "89",
+		},
+		{
+			name:         "Out of bounds range",
+			byteRange:    ByteRange{Unit: "bytes", Start: 10, End: 20},
+			expected:     true,
+			expectedCode: StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */10",
+			body:         "",
+		},
+		{
+			name:         "Empty suffix range",
+			byteRange:    ByteRange{Unit: "bytes", Start: -1, End: -1, SuffixLength: 0},
+			expected:     true,
+			expectedCode: StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */10",
+			body:         "",
+		},
+		{
+			name:         "No range",
+			byteRange:    ByteRange{},
+			expected:     false,
+			expectedCode: StatusOK,
+			contentRange: "",
+			body:         "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{Headers: RequestHeaders{Range: tt.byteRange}}
+			rw := ResponseWriter{response: getDefaultResponse()}
+
+			res := rw.ServePartial(full, r)
+
+			assert.Equal(t, res, tt.expected)
+			assert.Equal(t, rw.response.code, tt.expectedCode)
+			assert.Equal(t, string(rw.response.headers.contentRange.marshal()), tt.contentRange)
+			assert.Equal(t, string(rw.response.body), tt.body)
+			assert.Equal(t, rw.response.headers.contentLength, ContentLength(len(tt.body)))
+		})
+	}
+}
@@ -1,12 +1,20 @@
 package http
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net"
 	"os"
+	"sync"
 	"time"
+
+	"github.com/tony-montemuro/http/internal/constructs"
 )
 
 type Handler interface {
@@ -19,102 +27,558 @@ func (h HandlerFunc) ServeHTTP(r Request, w *ResponseWriter) {
 	h(r, w)
 }
 
+// ConnState is a stage in the life of a client connection, reported to Server.ConnState.
+type ConnState int
+
+const (
+	// StateNew is a connection that was just accepted, and has not yet sent a request.
+	StateNew ConnState = iota
+	// StateActive is a connection whose request is being handled.
+	StateActive
+	// StateIdle is a kept-alive connection waiting for its next request.
+	StateIdle
+	// StateClosed is a connection that has been closed.
+	StateClosed
+)
+
+func (cs ConnState) String() string {
+	switch cs {
+	case StateNew:
+		return "new"
+	case StateActive:
+		return "active"
+	case StateIdle:
+		return "idle"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// MethodLister is implemented by handlers that know which methods they support. The server uses it to answer OPTIONS *
+// requests; handlers that do not implement it are assumed to support GET, HEAD and POST.
+type MethodLister interface {
+	Methods() []Method
+}
+
+const (
+	defaultMaxHeaderBytes     = 4000
+	defaultMaxHeaderCount     = 100
+	defaultMaxURILength       = 2048
+	defaultMaxBodyBytes       = 64000
+	defaultDecompressionRatio = 10
+	requestLineOverhead       = 64
+)
+
+// defaultMaxRequestLineBytes leaves room for the method and version around a Request-URI of maxUriLength bytes.
+func defaultMaxRequestLineBytes(maxUriLength uint16) uint16 {
+	return uint16(min(int(maxUriLength)+requestLineOverhead, math.MaxUint16))
+}
+
 type Server struct {
-	Handler        Handler
-	ErrorLog       *slog.Logger
-	MaxHeaderBytes uint16
-	MaxBodyBytes   uint64
-	Port           uint16
-	ReadTimeout    uint16
+	Handler               Handler
+	ErrorLog              *slog.Logger
+	ServerName            string
+	ServerVersion         string
+	MaxHeaderBytes        uint16
+	MaxHeaderCount        uint16
+	MaxURILength          uint16
+	MaxRequestLineBytes   uint16
+	MaxBodyBytes          uint64
+	MaxDecompressedBytes  uint64
+	StreamBodyThreshold   uint64
+	AllowedMethods        []Method
+	AllowAbsoluteURI      bool
+	AllowChunkedResponses bool
+	RejectObsFold         bool
+	MinHeaderReadRate     uint16
+	DisableAutoDate       bool
+	ConnState             func(net.Conn, ConnState)
+	TLSConfig             *tls.Config
+	MaxConnections        int
+	MaxConnectionWait     uint16
+	MaxKeepAliveRequests  uint16
+	Port                  uint16
+	ReadTimeout           uint16
+	HeaderTimeout         uint16
+	BodyTimeout           uint16
+	WriteTimeout          uint16
+	conns                 chan struct{}
+	state                 *serverState
+}
+
+// serverState is what Serve and Shutdown share. It sits behind a pointer so the copies of Server made by its value
+// receivers all see the same state.
+type serverState struct {
+	mu       sync.Mutex
+	inFlight sync.WaitGroup
+	listener net.Listener
+	active   map[net.Conn]ConnState
+	closed   bool
+}
+
+// stateInit guards the creation of a Server's state, which Serve and Shutdown may race to do.
+var stateInit sync.Mutex
+
+// Serve blocks accepting connections until the server fails to start, or until Shutdown is called, in which case
+// ErrServerClosed is returned.
+func (s *Server) Serve() error {
+	err := s.init()
+	if err != nil {
+		s.ErrorLog.Error(err.Error())
+		return err
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
+	if err != nil {
+		s.ErrorLog.Error("problem starting server", slog.String("error", err.Error()))
+		return err
+	}
+
+	return s.serve(ln)
 }
 
-func (s *Server) Serve() {
+// ServeTLS is like Serve, but expects HTTPS connections. The certificate and key in certFile and keyFile are added to
+// those in TLSConfig; both may be "" if TLSConfig already holds a certificate.
+func (s *Server) ServeTLS(certFile, keyFile string) error {
 	err := s.init()
 	if err != nil {
 		s.ErrorLog.Error(err.Error())
-		return
+		return err
+	}
+
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			s.ErrorLog.Error("could not load certificate", slog.String("error", err.Error()))
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		err := errors.New("no certificate specified")
+		s.ErrorLog.Error(err.Error())
+		return err
 	}
 
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
 		s.ErrorLog.Error("problem starting server", slog.String("error", err.Error()))
-		return
+		return err
 	}
 
+	return s.serve(tls.NewListener(ln, config))
+}
+
+// serve accepts connections from ln until Shutdown is called.
+func (s *Server) serve(ln net.Listener) error {
+	state := s.state
+	state.mu.Lock()
+	if state.closed {
+		state.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	state.listener = ln
+	state.mu.Unlock()
+
 	fmt.Printf("Listening for connections on port %d...", s.Port)
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
 			fmt.Fprintf(os.Stderr, "could not accept connection: %s", err.Error())
+			continue
+		}
+
+		// Shutdown must either see this connection counted, or have closed the server before it was accepted
+		state.mu.Lock()
+		if state.closed {
+			state.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
 		}
-		go s.handle(conn)
+		state.inFlight.Add(1)
+		state.mu.Unlock()
+
+		if !s.acquireConn() {
+			go func() {
+				defer state.inFlight.Done()
+				s.rejectConn(conn)
+			}()
+			continue
+		}
+
+		go func() {
+			defer state.inFlight.Done()
+			defer s.releaseConn()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Shutdown stops the server from accepting new connections, closes connections idling between requests, and waits
+// for the rest to finish. If ctx is done first, its error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	state := s.initSync()
+
+	state.mu.Lock()
+	state.closed = true
+	ln := state.listener
+	for c, cs := range state.active {
+		if cs == StateIdle {
+			c.Close()
+		}
+	}
+	state.mu.Unlock()
+
+	if ln != nil {
+		err := ln.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		state.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquireConn takes one of the MaxConnections slots, waiting for one to free up. If MaxConnectionWait passes first, it
+// gives up and returns false.
+func (s *Server) acquireConn() bool {
+	if s.conns == nil {
+		return true
+	}
+
+	if s.MaxConnectionWait == 0 {
+		s.conns <- struct{}{}
+		return true
+	}
+
+	timer := time.NewTimer(time.Duration(s.MaxConnectionWait) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case s.conns <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (s *Server) releaseConn() {
+	if s.conns != nil {
+		<-s.conns
+	}
+}
+
+// rejectConn answers a connection that could not get a slot with 503 Service Unavailable, then closes it.
+func (s *Server) rejectConn(c net.Conn) {
+	defer c.Close()
+
+	err := ServerError{message: fmt.Sprintf("server is at its limit of %d connections", s.MaxConnections), status: StatusServiceUnavailable}
+	s.ErrorLog.Error(err.Error())
+	s.send(c, bufio.NewWriter(c), getErrorResponse(err))
+}
+
+func (s Server) isClosed() bool {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	return s.state.closed
+}
+
+// parseOptions returns the limits requests to the server are parsed under.
+func (s Server) parseOptions() ParseOptions {
+	return ParseOptions{
+		MaxHeaderBytes:       s.MaxHeaderBytes,
+		MaxHeaderCount:       s.MaxHeaderCount,
+		MaxURILength:         s.MaxURILength,
+		MaxRequestLineBytes:  s.MaxRequestLineBytes,
+		MaxBodyBytes:         s.MaxBodyBytes,
+		MaxDecompressedBytes: s.MaxDecompressedBytes,
+		StreamBodyThreshold:  s.StreamBodyThreshold,
+		AllowedMethods:       s.AllowedMethods,
+		AllowAbsoluteURI:     s.AllowAbsoluteURI,
+		RejectObsFold:        s.RejectObsFold,
+		MinHeaderReadRate:    s.MinHeaderReadRate,
+		HeaderTimeout:        s.headerTimeout(),
+		BodyTimeout:          s.bodyTimeout(),
+	}
+}
+
+// headerTimeout is how long the server waits for the request line and headers, falling back to ReadTimeout.
+func (s Server) headerTimeout() time.Duration {
+	if s.HeaderTimeout == 0 {
+		return time.Duration(s.ReadTimeout) * time.Millisecond
 	}
+	return time.Duration(s.HeaderTimeout) * time.Millisecond
+}
+
+// bodyTimeout is how long the server waits for the request body, falling back to ReadTimeout.
+func (s Server) bodyTimeout() time.Duration {
+	if s.BodyTimeout == 0 {
+		return time.Duration(s.ReadTimeout) * time.Millisecond
+	}
+	return time.Duration(s.BodyTimeout) * time.Millisecond
+}
+
+// initSync returns the server's state, creating it if neither Serve nor Shutdown has yet.
+func (s *Server) initSync() *serverState {
+	stateInit.Lock()
+	defer stateInit.Unlock()
+
+	if s.state == nil {
+		s.state = &serverState{}
+	}
+	return s.state
 }
 
 func (s *Server) init() error {
+	s.initSync()
 	if s.ErrorLog == nil {
 		s.ErrorLog = slog.New(slog.NewTextHandler(os.Stdout, nil))
 	}
 	if s.Handler == nil {
 		return errors.New("no handler specified")
 	}
+	if s.ServerName == "" && s.ServerVersion != "" {
+		return errors.New("server version specified without a server name")
+	}
+	if s.ServerName != "" {
+		err := constructs.ValidateToken(s.ServerName)
+		if err != nil {
+			return fmt.Errorf("invalid server name: %s", err.Error())
+		}
+	}
+	if s.ServerVersion != "" {
+		err := constructs.ValidateToken(s.ServerVersion)
+		if err != nil {
+			return fmt.Errorf("invalid server version: %s", err.Error())
+		}
+	}
 	if s.Port == 0 {
 		s.Port = 8080
 	}
 	if s.ReadTimeout == 0 {
 		s.ReadTimeout = 5000
 	}
+	if s.WriteTimeout == 0 {
+		s.WriteTimeout = 5000
+	}
 	if s.MaxHeaderBytes == 0 {
-		s.MaxHeaderBytes = 4000
+		s.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if s.MaxHeaderCount == 0 {
+		s.MaxHeaderCount = defaultMaxHeaderCount
+	}
+	if s.MaxURILength == 0 {
+		s.MaxURILength = defaultMaxURILength
+	}
+	if s.MaxRequestLineBytes == 0 {
+		s.MaxRequestLineBytes = defaultMaxRequestLineBytes(s.MaxURILength)
 	}
 	if s.MaxBodyBytes == 0 {
-		s.MaxBodyBytes = 64000
+		s.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if s.MaxDecompressedBytes == 0 {
+		s.MaxDecompressedBytes = defaultDecompressionRatio * s.MaxBodyBytes
+	}
+	if s.MaxConnections < 0 {
+		return errors.New("max connections cannot be negative")
+	}
+	if s.MaxConnections > 0 {
+		s.conns = make(chan struct{}, s.MaxConnections)
+	}
+	if s.MaxKeepAliveRequests == 0 {
+		s.MaxKeepAliveRequests = 100
 	}
 
 	return nil
 }
 
-func (s Server) handle(c net.Conn) {
-	request, err := parseRequest(c, s)
-	if err != nil {
-		s.ErrorLog.Error(err.Error())
-		s.send(c, getErrorResponse(err))
-		return
+func (s *Server) handle(c net.Conn) {
+	s.setState(c, StateNew)
+	defer func() {
+		c.Close()
+		s.setState(c, StateClosed)
+	}()
+	reader := newRequestReader(c)
+	writer := bufio.NewWriter(c)
+	opts := s.parseOptions()
+
+	for served := uint16(1); ; served++ {
+		request, err := reader.next(opts)
+		if err != nil {
+			// a connection idling between requests may also have been closed by Shutdown
+			if errors.Is(err, errNoRequest) || (served > 1 && (isIdleConnError(err) || s.isClosed())) {
+				return
+			}
+
+			s.ErrorLog.Error(err.Error())
+			s.send(c, writer, getErrorResponse(err))
+			return
+		}
+
+		s.setState(c, StateActive)
+		w := ResponseWriter{
+			response:      getDefaultResponse(),
+			conn:          c,
+			discardBody:   request.Line.Method == MethodHead,
+			chunked:       s.AllowChunkedResponses,
+			version:       request.Line.Version,
+			writeTimeout:  time.Duration(s.WriteTimeout) * time.Millisecond,
+			defaultServer: s.serverProduct(),
+			autoDate:      !s.DisableAutoDate,
+		}
+		handler := s.Handler
+		if request.Line.Method == MethodOptions && string(request.Line.Uri.Path) == "*" {
+			handler = HandlerFunc(s.serveOptions)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		request.ctx = ctx
+		stopWatch := func() {}
+		if request.body == nil {
+			stopWatch = reader.watchClose(cancel)
+		}
+		handler.ServeHTTP(*request, &w)
+		stopWatch()
+		cancel()
+		w.written = true
+
+		if w.stream != nil {
+			err = w.stream.Close()
+			if err != nil {
+				s.ErrorLog.Error("could not stream body:", slog.String("message", err.Error()))
+			}
+			return
+		}
+
+		keepAlive := s.keepAlive(*request, w, served)
+		if keepAlive && request.body != nil {
+			keepAlive = request.body.discard() == nil
+		}
+		if keepAlive {
+			w.response.headers.connection = ConnectionOptions{"keep-alive"}
+		}
+
+		err = prepareBody(request, &w)
+		if err != nil {
+			s.ErrorLog.Error(err.Error())
+			w.response = getErrorResponse(err)
+			keepAlive = false
+		}
+
+		err = s.send(c, writer, w.response)
+		if err != nil || !keepAlive {
+			return
+		}
+
+		// Shutdown closes connections it sees idle; one that goes idle after Shutdown must close itself
+		s.setState(c, StateIdle)
+		if s.isClosed() {
+			return
+		}
 	}
+}
 
-	w := ResponseWriter{response: getDefaultResponse()}
-	s.Handler.ServeHTTP(*request, &w)
+// serverProduct returns the product sent in the Server header of responses whose handler did not set one.
+func (s Server) serverProduct() ProductVersion {
+	return ProductVersion{Product: s.ServerName, Version: s.ServerVersion}
+}
 
-	err = prepareBody(request, &w)
-	if err != nil {
-		s.ErrorLog.Error(err.Error())
-		w.response = getErrorResponse(err)
+// setState records the state of c, so Shutdown can find idle connections, and reports it to the ConnState hook.
+func (s Server) setState(c net.Conn, state ConnState) {
+	s.state.mu.Lock()
+	if state == StateClosed {
+		delete(s.state.active, c)
+	} else {
+		if s.state.active == nil {
+			s.state.active = make(map[net.Conn]ConnState)
+		}
+		s.state.active[c] = state
 	}
+	s.state.mu.Unlock()
 
-	s.send(c, w.response)
+	if s.ConnState != nil {
+		s.ConnState(c, state)
+	}
+}
+
+func (s Server) serveOptions(r Request, w *ResponseWriter) {
+	methods := []Method{MethodGet, MethodHead, MethodPost}
+	if lister, ok := s.Handler.(MethodLister); ok {
+		methods = lister.Methods()
+	}
+
+	for _, m := range methods {
+		w.AddAllowHeader([]byte(m))
+	}
+}
+
+func (s Server) keepAlive(r Request, w ResponseWriter, served uint16) bool {
+	return r.Headers.WantsKeepAlive() && !w.response.headers.connection.Has("close") && served < s.MaxKeepAliveRequests
+}
+
+func isIdleConnError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded)
 }
 
-func (s Server) send(c net.Conn, r response) {
-	marshaled := r.marshal()
-	_, err := c.Write(marshaled)
+// send writes r to c through w, which buffers the connection, so the response reaches c in as few writes as possible.
+func (s *Server) send(c net.Conn, w *bufio.Writer, r response) error {
+	c.SetWriteDeadline(time.Now().Add(time.Duration(s.WriteTimeout) * time.Millisecond))
+	defer c.SetWriteDeadline(time.Time{})
+
+	r.headers.setDefaultServer(s.serverProduct())
+	if !s.DisableAutoDate {
+		r.headers.setDefaultDate()
+	}
+
+	err := r.writeTo(w)
 	if err != nil {
 		s.ErrorLog.Error("could not send data:", slog.String("message", err.Error()))
 	}
 
-	c.Close()
+	return err
 }
 
 func prepareBody(r *Request, w *ResponseWriter) error {
+	w.response.head = r.Line.Method == MethodHead
+	return w.response.encodeBody()
+}
+
+// encodeBody applies the response's Content-Encoding to its body, which is dropped entirely for 304 Not Modified.
+func (r *response) encodeBody() error {
 	var err error
 	var body []byte
 
-	if r.Line.Method == MethodHead || w.response.code == StatusNotModified {
+	if r.code == StatusNotModified {
 		body = []byte{}
 	} else {
-		body, err = encodeRequestBody(w.response.body, w.response.headers.contentEncoding)
+		body, err = encodeRequestBody(r.body, r.headers.contentEncoding)
+		r.headers.contentLength = ContentLength(len(body))
 	}
 
-	w.response.body = body
+	r.body = body
 	return err
 }
 
@@ -122,7 +586,6 @@ func getDefaultResponse() response {
 	return response{
 		code: StatusOK,
 		headers: responseHeaders{
-			date:        MessageTime{date: prepareTime(time.Now())},
 			contentType: ContentType{Type: "application", Subtype: "octet-stream"},
 		},
 	}
@@ -134,14 +597,22 @@ func getErrorResponse(e error) response {
 	switch err := e.(type) {
 	case ClientError:
 		r.code = StatusBadRequest
+		if err.status != 0 {
+			r.code = code(err.status)
+		}
 		r.body = []byte(err.Error())
 	case ServerError:
 		r.code = StatusInternalServerError
+		if err.status != 0 {
+			r.code = code(err.status)
+		}
 		r.body = []byte(err.Error())
 	default:
 		r.code = StatusInternalServerError
 		r.body = []byte(err.Error())
 	}
 
+	r.headers.contentType = ContentType{Type: "text", Subtype: "plain"}
+	r.headers.contentLength = ContentLength(len(r.body))
 	return r
 }
@@ -0,0 +1,1511 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tony-montemuro/http/internal/assert"
+)
+
+type testResponse struct {
+	line    string
+	headers map[string]string
+	body    string
+}
+
+func readTestResponse(t *testing.T, r *bufio.Reader) (testResponse, error) {
+	t.Helper()
+	res := testResponse{headers: make(map[string]string)}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return res, err
+	}
+	res.line = strings.TrimSuffix(line, "\r\n")
+
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return res, err
+		}
+		if header == "\r\n" {
+			break
+		}
+
+		name, value, _ := strings.Cut(strings.TrimSuffix(header, "\r\n"), ": ")
+		res.headers[name] = value
+	}
+
+	length, err := strconv.Atoi(res.headers["Content-Length"])
+	if err != nil {
+		body, err := io.ReadAll(r)
+		res.body = string(body)
+		return res, err
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	res.body = string(body)
+	return res, err
+}
+
+func newTestServer(h HandlerFunc) Server {
+	s := Server{Handler: h, ErrorLog: slog.New(slog.DiscardHandler)}
+	s.init()
+	return s
+}
+
+func TestServer_handleKeepAlive(t *testing.T) {
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		w.SetBody(append([]byte("hello "), r.Line.Uri.Path...))
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte(
+			"GET /first HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n" +
+				"GET /second HTTP/1.0\r\n\r\n",
+		))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+
+	first, err := readTestResponse(t, reader)
+	if err != nil {
+		t.Fatalf("could not read first response: %s", err.Error())
+	}
+	assert.Equal(t, first.line, "HTTP/1.0 200 OK")
+	assert.Equal(t, first.headers["Connection"], "keep-alive")
+	assert.Equal(t, first.body, "hello /first")
+
+	second, err := readTestResponse(t, reader)
+	if err != nil {
+		t.Fatalf("could not read second response: %s", err.Error())
+	}
+	assert.Equal(t, second.line, "HTTP/1.0 200 OK")
+	assert.Equal(t, second.headers["Connection"], "")
+	assert.Equal(t, second.body, "hello /second")
+
+	_, err = reader.ReadByte()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestServer_handleKeepsRawHeaders(t *testing.T) {
+	kept := make(chan Request, 2)
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		kept <- r
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte(
+			"GET /first HTTP/1.0\r\nConnection: Keep-Alive\r\nX-Custom: first\r\n\r\n" +
+				"GET /second HTTP/1.0\r\nX-Custom: second\r\n\r\n",
+		))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+	for range 2 {
+		_, err := readTestResponse(t, reader)
+		if err != nil {
+			t.Fatalf("could not read response: %s", err.Error())
+		}
+	}
+
+	first, second := <-kept, <-kept
+	value, _ := first.GetRawHeader("X-Custom")
+	assert.Equal(t, value, "first")
+	value, _ = second.GetRawHeader("X-Custom")
+	assert.Equal(t, value, "second")
+}
+
+func TestServer_handleKeepAliveStreamedBody(t *testing.T) {
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		if r.Line.Method == MethodPost {
+			prefix := make([]byte, 4)
+			io.ReadFull(r.BodyReader(), prefix)
+			w.SetBody(prefix)
+			return
+		}
+
+		w.SetBody(append([]byte("hello "), r.Line.Uri.Path...))
+	})
+	s.StreamBodyThreshold = 8
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte(
+			"POST /upload HTTP/1.0\r\nConnection: Keep-Alive\r\nContent-Length: 16\r\n\r\nabcdefghijklmnop" +
+				"GET /second HTTP/1.0\r\n\r\n",
+		))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+
+	first, err := readTestResponse(t, reader)
+	if err != nil {
+		t.Fatalf("could not read first response: %s", err.Error())
+	}
+	assert.Equal(t, first.headers["Connection"], "keep-alive")
+	assert.Equal(t, first.body, "abcd")
+
+	second, err := readTestResponse(t, reader)
+	if err != nil {
+		t.Fatalf("could not read second response: %s", err.Error())
+	}
+	assert.Equal(t, second.line, "HTTP/1.0 200 OK")
+	assert.Equal(t, second.body, "hello /second")
+}
+
+func TestServer_handleHead(t *testing.T) {
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		w.SetBody([]byte("hello world"))
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte("HEAD / HTTP/1.0\r\n\r\n"))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+
+	head, body, _ := strings.Cut(string(res), "\r\n\r\n")
+	assert.Equal(t, strings.HasPrefix(head, "HTTP/1.0 200 OK\r\n"), true)
+	assert.Equal(t, strings.Contains(head, "\r\nContent-Length: 11\r\n"), true)
+	assert.Equal(t, body, "")
+}
+
+func TestServer_handleChunkedResponse(t *testing.T) {
+	writes := []string{"first", "second chunk", "the third and final chunk"}
+
+	tests := []struct {
+		name    string
+		request string
+		chunked bool
+		line    string
+	}{
+		{"HTTP/1.1 request", "GET / HTTP/1.1\r\n\r\n", true, "HTTP/1.1 200 OK\r\n"},
+		{"HTTP/1.0 request", "GET / HTTP/1.0\r\n\r\n", false, "HTTP/1.0 200 OK\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(func(r Request, w *ResponseWriter) {
+				bw := w.BodyWriter()
+				for _, data := range writes {
+					bw.Write([]byte(data))
+				}
+				bw.Close()
+			})
+			s.AllowChunkedResponses = true
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			go func() {
+				client.Write([]byte(tt.request))
+			}()
+
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			res, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+
+			head, body, _ := strings.Cut(string(res), "\r\n\r\n")
+			assert.Equal(t, strings.HasPrefix(head, tt.line), true)
+			assert.Equal(t, strings.Contains(head, "\r\nTransfer-Encoding: chunked"), tt.chunked)
+			assert.Equal(t, strings.Contains(head, "Content-Length"), false)
+			assert.Equal(t, strings.Contains(head, "\r\nConnection: close"), true)
+
+			if !tt.chunked {
+				assert.Equal(t, body, strings.Join(writes, ""))
+				return
+			}
+
+			var chunks []string
+			for {
+				sizeLine, rest, ok := strings.Cut(body, "\r\n")
+				if !ok {
+					t.Fatalf("chunk size line is not terminated by CRLF (%q)", body)
+				}
+
+				size, err := strconv.ParseUint(sizeLine, 16, 64)
+				if err != nil {
+					t.Fatalf("malformed chunk size (%q)", sizeLine)
+				}
+				if size == 0 {
+					assert.Equal(t, rest, "\r\n")
+					break
+				}
+
+				if uint64(len(rest)) < size+2 || rest[size:size+2] != "\r\n" {
+					t.Fatalf("chunk data is not terminated by CRLF (%q)", rest)
+				}
+				chunks = append(chunks, rest[:size])
+				body = rest[size+2:]
+			}
+
+			assert.SliceEqual(t, chunks, writes)
+		})
+	}
+}
+
+func TestServer_serveChunkedResponseToClient(t *testing.T) {
+	writes := []string{"first", "second chunk", "the third and final chunk"}
+
+	s := &Server{
+		Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+			bw := w.BodyWriter()
+			for _, data := range writes {
+				bw.Write([]byte(data))
+			}
+			bw.Close()
+		}),
+		ErrorLog:              slog.New(slog.DiscardHandler),
+		AllowChunkedResponses: true,
+	}
+	err := s.init()
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	go s.serve(ln)
+	defer s.Shutdown(context.Background())
+
+	client := &nethttp.Client{Timeout: 5 * time.Second}
+	res, err := client.Get(fmt.Sprintf("http://%s/", ln.Addr().String()))
+	if err != nil {
+		t.Fatalf("could not get response: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %s", err.Error())
+	}
+
+	assert.Equal(t, res.StatusCode, 200)
+	assert.Equal(t, res.Proto, "HTTP/1.1")
+	assert.SliceEqual(t, res.TransferEncoding, []string{"chunked"})
+	assert.Equal(t, string(body), strings.Join(writes, ""))
+}
+
+func TestServer_handleStreamWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		readHead bool
+	}{
+		{"Client reads nothing", false},
+		{"Client stops reading after the head", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := make(chan error, 1)
+			s := newTestServer(func(r Request, w *ResponseWriter) {
+				bw := w.BodyWriter()
+				_, err := bw.Write([]byte("hello"))
+				result <- err
+			})
+			s.WriteTimeout = 50
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+			client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+			if tt.readHead {
+				_, err := readTestHead(bufio.NewReader(client))
+				if err != nil {
+					t.Fatalf("could not read response head: %s", err.Error())
+				}
+			}
+
+			select {
+			case err := <-result:
+				assert.Equal(t, errors.Is(err, os.ErrDeadlineExceeded), true)
+			case <-time.After(2 * time.Second):
+				t.Fatal("streamed write to a blocked client did not time out")
+			}
+		})
+	}
+}
+
+// readTestHead reads a response's status line and headers, leaving the body unread.
+func readTestHead(r *bufio.Reader) (string, error) {
+	var head strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return head.String(), err
+		}
+		head.WriteString(line)
+		if line == "\r\n" {
+			return head.String(), nil
+		}
+	}
+}
+
+func TestServer_handleCommitsResponse(t *testing.T) {
+	var rw *ResponseWriter
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		rw = w
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := readTestResponse(t, bufio.NewReader(client))
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+	assert.Equal(t, res.line, "HTTP/1.0 200 OK")
+
+	assert.Equal(t, rw.Written(), true)
+	err = rw.SetStatus(StatusInternalServerError)
+	assert.Equal(t, errors.Is(err, ErrResponseWritten), true)
+}
+
+func TestServer_handleServerHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverName    string
+		serverVersion string
+		handler       HandlerFunc
+		expected      string
+	}{
+		{
+			name:          "Default server header",
+			serverName:    "tony",
+			serverVersion: "1.0",
+			handler:       func(r Request, w *ResponseWriter) {},
+			expected:      "tony/1.0",
+		},
+		{
+			name:       "Default server header without version",
+			serverName: "tony",
+			handler:    func(r Request, w *ResponseWriter) {},
+			expected:   "tony",
+		},
+		{
+			name:          "Handler header takes precedence",
+			serverName:    "tony",
+			serverVersion: "1.0",
+			handler: func(r Request, w *ResponseWriter) {
+				w.AddServerHeader([]byte("custom/2.0"))
+			},
+			expected: "custom/2.0",
+		},
+		{
+			name:     "No default",
+			handler:  func(r Request, w *ResponseWriter) {},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(tt.handler)
+			s.ServerName = tt.serverName
+			s.ServerVersion = tt.serverVersion
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			go func() {
+				client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+			}()
+
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			res, err := readTestResponse(t, bufio.NewReader(client))
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+
+			assert.Equal(t, res.headers["Server"], tt.expected)
+		})
+	}
+}
+
+func TestServer_handleDate(t *testing.T) {
+	date := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		disableAutoDate bool
+		handler         HandlerFunc
+		expected        string
+	}{
+		{
+			name:     "Handler preserves its date",
+			handler:  func(r Request, w *ResponseWriter) { w.SetDateHeader(date) },
+			expected: "Sun, 06 Nov 1994 08:49:37 GMT",
+		},
+		{
+			name:            "Handler date with auto date disabled",
+			disableAutoDate: true,
+			handler:         func(r Request, w *ResponseWriter) { w.SetDateHeader(date) },
+			expected:        "Sun, 06 Nov 1994 08:49:37 GMT",
+		},
+		{
+			name:            "No date with auto date disabled",
+			disableAutoDate: true,
+			handler:         func(r Request, w *ResponseWriter) {},
+			expected:        "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(tt.handler)
+			s.DisableAutoDate = tt.disableAutoDate
+
+			res := serveTestRequest(t, s, "GET / HTTP/1.0\r\n\r\n")
+			assert.Equal(t, res.headers["Date"], tt.expected)
+		})
+	}
+}
+
+func TestServer_handleAutoDate(t *testing.T) {
+	s := newTestServer(func(r Request, w *ResponseWriter) {})
+
+	before := time.Now().Truncate(time.Second)
+	res := serveTestRequest(t, s, "GET / HTTP/1.0\r\n\r\n")
+	after := time.Now()
+
+	date, err := time.Parse(time.RFC1123, res.headers["Date"])
+	if err != nil {
+		t.Fatalf("could not parse Date header: %s", err.Error())
+	}
+	assert.Equal(t, date.Before(before), false)
+	assert.Equal(t, date.After(after), false)
+}
+
+// serveTestRequest sends data to s over a pipe, and reads back its response.
+func serveTestRequest(t *testing.T, s Server, data string) testResponse {
+	t.Helper()
+
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go s.handle(server)
+
+	go func() {
+		client.Write([]byte(data))
+	}()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := readTestResponse(t, bufio.NewReader(client))
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+
+	return res
+}
+
+func TestServer_handleContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		closeClient bool
+		expected    error
+	}{
+		{"Client closes connection", true, context.Canceled},
+		{"Client waits for response", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := make(chan error, 1)
+			s := newTestServer(func(r Request, w *ResponseWriter) {
+				select {
+				case <-r.Context().Done():
+					result <- r.Context().Err()
+				case <-time.After(100 * time.Millisecond):
+					result <- r.Context().Err()
+				}
+			})
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+			if tt.closeClient {
+				client.Close()
+			} else {
+				go io.Copy(io.Discard, client)
+			}
+
+			select {
+			case err := <-result:
+				assert.Equal(t, err, tt.expected)
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler did not return")
+			}
+		})
+	}
+}
+
+func TestServer_handleKeepAliveAfterContextWatch(t *testing.T) {
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		w.SetBody(append([]byte("hello "), r.Line.Uri.Path...))
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go s.handle(server)
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+	for _, path := range []string{"/first", "/second"} {
+		client.Write([]byte("GET " + path + " HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n"))
+		res, err := readTestResponse(t, reader)
+		if err != nil {
+			t.Fatalf("could not read response: %s", err.Error())
+		}
+		assert.Equal(t, res.body, "hello "+path)
+	}
+}
+
+func TestServer_initServerProduct(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverName    string
+		serverVersion string
+		expectError   bool
+	}{
+		{
+			name:          "Name and version",
+			serverName:    "tony",
+			serverVersion: "1.0",
+			expectError:   false,
+		},
+		{
+			name:        "Invalid name",
+			serverName:  "tony server",
+			expectError: true,
+		},
+		{
+			name:          "Invalid version",
+			serverName:    "tony",
+			serverVersion: "1/0",
+			expectError:   true,
+		},
+		{
+			name:          "Version without name",
+			serverVersion: "1.0",
+			expectError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Server{Handler: HandlerFunc(func(r Request, w *ResponseWriter) {}), ServerName: tt.serverName, ServerVersion: tt.serverVersion}
+			err := s.init()
+			assert.ErrorStatus(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestServer_handleOptions(t *testing.T) {
+	mux := &ServeMux{}
+	h := HandlerFunc(func(r Request, w *ResponseWriter) {})
+	mux.Handle("GET", "/users", h)
+	mux.Handle("POST", "/users", h)
+	mux.Handle("GET", "/users/:id", h)
+
+	tests := []struct {
+		name     string
+		handler  Handler
+		expected string
+	}{
+		{
+			name:     "ServeMux lists its methods",
+			handler:  mux,
+			expected: "GET, POST",
+		},
+		{
+			name:     "Plain handler",
+			handler:  h,
+			expected: "GET, HEAD, POST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Server{Handler: tt.handler, ErrorLog: slog.New(slog.DiscardHandler)}
+			s.init()
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			go func() {
+				client.Write([]byte("OPTIONS * HTTP/1.0\r\n\r\n"))
+			}()
+
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			res, err := readTestResponse(t, bufio.NewReader(client))
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+
+			assert.Equal(t, res.line, "HTTP/1.0 200 OK")
+			assert.Equal(t, res.headers["Allow"], tt.expected)
+			assert.Equal(t, res.body, "")
+		})
+	}
+}
+
+func TestServer_ConnState(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected []ConnState
+	}{
+		{
+			name:     "Single request",
+			request:  "GET / HTTP/1.0\r\n\r\n",
+			expected: []ConnState{StateNew, StateActive, StateClosed},
+		},
+		{
+			name:     "Keep-alive requests",
+			request:  "GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\nGET / HTTP/1.0\r\n\r\n",
+			expected: []ConnState{StateNew, StateActive, StateIdle, StateActive, StateClosed},
+		},
+		{
+			name:     "Malformed request",
+			request:  "GET\r\n\r\n",
+			expected: []ConnState{StateNew, StateClosed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var states []ConnState
+			s := newTestServer(func(r Request, w *ResponseWriter) {})
+			s.ConnState = func(c net.Conn, state ConnState) {
+				states = append(states, state)
+			}
+
+			server, client := net.Pipe()
+			defer client.Close()
+
+			go func() {
+				client.Write([]byte(tt.request))
+				io.Copy(io.Discard, client)
+			}()
+
+			s.handle(server)
+			assert.SliceEqual(t, states, tt.expected)
+		})
+	}
+}
+
+// blockedConn is a net.Conn belonging to a client that never reads, so writes block until the write deadline passes.
+type blockedConn struct {
+	net.Conn
+	deadlines []time.Time
+}
+
+func (c *blockedConn) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func (c *blockedConn) Write(b []byte) (int, error) {
+	deadline := c.deadlines[len(c.deadlines)-1]
+	if deadline.IsZero() {
+		return 0, errors.New("write would block forever")
+	}
+
+	time.Sleep(time.Until(deadline))
+	return 0, os.ErrDeadlineExceeded
+}
+
+func TestServer_sendWriteTimeout(t *testing.T) {
+	var logs bytes.Buffer
+	s := newTestServer(func(r Request, w *ResponseWriter) {})
+	s.ErrorLog = slog.New(slog.NewTextHandler(&logs, nil))
+	s.WriteTimeout = 50
+
+	conn := &blockedConn{}
+	start := time.Now()
+	err := s.send(conn, bufio.NewWriter(conn), getDefaultResponse())
+
+	assert.Equal(t, errors.Is(err, os.ErrDeadlineExceeded), true)
+	assert.Equal(t, len(conn.deadlines), 2)
+	assert.Equal(t, conn.deadlines[0].Sub(start) >= 50*time.Millisecond, true)
+	assert.Equal(t, conn.deadlines[1].IsZero(), true)
+	assert.Equal(t, strings.Contains(logs.String(), "could not send data"), true)
+}
+
+// recordingConn is a net.Conn that records every write made to it.
+type recordingConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (c *recordingConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, bytes.Clone(b))
+	return len(b), nil
+}
+
+// discardConn is a net.Conn that accepts and drops every write.
+type discardConn struct {
+	net.Conn
+}
+
+func (c discardConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c discardConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// idleConn is a net.Conn whose reads time out without returning any data, and whose writes are recorded.
+type idleConn struct {
+	recordingConn
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	return 0, os.ErrDeadlineExceeded
+}
+
+func (c *idleConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *idleConn) Close() error {
+	return nil
+}
+
+func TestServer_handleEmptyRequest(t *testing.T) {
+	var logs bytes.Buffer
+	s := newTestServer(func(r Request, w *ResponseWriter) {
+		t.Error("handler should not be called")
+	})
+	s.ErrorLog = slog.New(slog.NewTextHandler(&logs, nil))
+
+	c := &idleConn{}
+	s.handle(c)
+
+	assert.Equal(t, len(c.writes), 0)
+	assert.Equal(t, logs.String(), "")
+}
+
+func TestServer_send(t *testing.T) {
+	large := getDefaultResponse()
+	large.body = bytes.Repeat([]byte("a"), 64000)
+
+	head := getDefaultResponse()
+	head.body = []byte("hello world")
+	head.head = true
+
+	tests := []struct {
+		name     string
+		response response
+	}{
+		{
+			name:     "Empty response",
+			response: getDefaultResponse(),
+		},
+		{
+			name:     "Large body",
+			response: large,
+		},
+		{
+			name:     "Response to HEAD request",
+			response: head,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(func(r Request, w *ResponseWriter) {})
+			conn := &recordingConn{}
+			tt.response.headers.date = MessageTime{date: prepareTime(time.Now())}
+
+			err := s.send(conn, bufio.NewWriter(conn), tt.response)
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err.Error())
+			}
+
+			assert.SliceEqual(t, bytes.Join(conn.writes, nil), tt.response.marshal())
+			assert.Equal(t, len(conn.writes) <= 2, true)
+		})
+	}
+}
+
+func BenchmarkServeSmallResponse(b *testing.B) {
+	s := Server{ErrorLog: slog.New(slog.DiscardHandler), WriteTimeout: 5000}
+	r := getDefaultResponse()
+	r.headers.contentType = ContentType{Type: "text", Subtype: "plain"}
+	r.body = []byte("hello world")
+
+	conn := discardConn{}
+	w := bufio.NewWriter(conn)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		s.send(conn, w, r)
+	}
+}
+
+func TestServer_keepAlive(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   ConnectionOptions
+		response  ConnectionOptions
+		served    uint16
+		maxServed uint16
+		expected  bool
+	}{
+		{
+			name:      "Client requests keep-alive",
+			request:   ConnectionOptions{"Keep-Alive"},
+			served:    1,
+			maxServed: 100,
+			expected:  true,
+		},
+		{
+			name:      "Client does not request keep-alive",
+			served:    1,
+			maxServed: 100,
+			expected:  false,
+		},
+		{
+			name:      "Handler closes connection",
+			request:   ConnectionOptions{"keep-alive"},
+			response:  ConnectionOptions{"close"},
+			served:    1,
+			maxServed: 100,
+			expected:  false,
+		},
+		{
+			name:      "Max requests per connection reached",
+			request:   ConnectionOptions{"keep-alive"},
+			served:    3,
+			maxServed: 3,
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Server{MaxKeepAliveRequests: tt.maxServed}
+			r := Request{Headers: RequestHeaders{Connection: tt.request}}
+			w := ResponseWriter{response: response{headers: responseHeaders{connection: tt.response}}}
+
+			assert.Equal(t, s.keepAlive(r, w, tt.served), tt.expected)
+		})
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	s := &Server{
+		Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+			w.SetBody([]byte("hello"))
+		}),
+		ErrorLog: slog.New(slog.DiscardHandler),
+		Port:     port,
+	}
+	addr := fmt.Sprintf("localhost:%d", port)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve()
+	}()
+
+	var conn net.Conn
+	for range 50 {
+		conn, err = net.Dial("tcp", addr)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("could not connect to server: %s", err.Error())
+	}
+
+	conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	res, err := readTestResponse(t, bufio.NewReader(conn))
+	conn.Close()
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+	assert.Equal(t, res.body, "hello")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = s.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	select {
+	case err := <-served:
+		assert.Equal(t, errors.Is(err, ErrServerClosed), true)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+
+	_, err = net.Dial("tcp", addr)
+	assert.ErrorStatus(t, err, true)
+}
+
+func TestServer_MaxConnections(t *testing.T) {
+	const maxConnections = 2
+
+	tests := []struct {
+		name              string
+		maxConnectionWait uint16
+		expectedStatus    string
+	}{
+		{"Wait for a slot", 0, "HTTP/1.0 200 OK"},
+		{"Give up waiting", 50, "HTTP/1.0 503 Service Unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", ":0")
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+			port := uint16(ln.Addr().(*net.TCPAddr).Port)
+			ln.Close()
+
+			entered := make(chan struct{}, maxConnections+1)
+			release := make(chan struct{})
+			s := &Server{
+				Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+					entered <- struct{}{}
+					<-release
+				}),
+				ErrorLog:          slog.New(slog.DiscardHandler),
+				Port:              port,
+				MaxConnections:    maxConnections,
+				MaxConnectionWait: tt.maxConnectionWait,
+			}
+			addr := fmt.Sprintf("localhost:%d", port)
+
+			go s.Serve()
+			defer s.Shutdown(context.Background())
+
+			dial := func() net.Conn {
+				var conn net.Conn
+				for range 50 {
+					conn, err = net.Dial("tcp", addr)
+					if err == nil {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				if err != nil {
+					t.Fatalf("could not connect to server: %s", err.Error())
+				}
+
+				conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+				return conn
+			}
+
+			var conns []net.Conn
+			for range maxConnections {
+				conn := dial()
+				defer conn.Close()
+				conns = append(conns, conn)
+				<-entered
+			}
+
+			extra := dial()
+			defer extra.Close()
+
+			select {
+			case <-entered:
+				t.Fatal("connection beyond MaxConnections was handled")
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			close(release)
+			res, err := readTestResponse(t, bufio.NewReader(extra))
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+			assert.Equal(t, res.line, tt.expectedStatus)
+
+			for _, conn := range conns {
+				res, err := readTestResponse(t, bufio.NewReader(conn))
+				if err != nil {
+					t.Fatalf("could not read response: %s", err.Error())
+				}
+				assert.Equal(t, res.line, "HTTP/1.0 200 OK")
+			}
+		})
+	}
+}
+
+// newTestCertificate returns a self-signed certificate for localhost, along with its PEM encoded certificate and key.
+func newTestCertificate(t *testing.T) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	return cert, certPEM, keyPEM
+}
+
+func TestServer_handleTLS(t *testing.T) {
+	cert, _, _ := newTestCertificate(t)
+
+	tests := []struct {
+		name     string
+		tls      bool
+		expected string
+	}{
+		{"TLS connection", true, "tls: true"},
+		{"Plain connection", false, "tls: false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(func(r Request, w *ResponseWriter) {
+				w.SetBody(fmt.Appendf(nil, "tls: %t", r.IsTLS()))
+			})
+
+			server, client := net.Pipe()
+			var serverConn, clientConn net.Conn = server, client
+			if tt.tls {
+				serverConn = tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}})
+				clientConn = tls.Client(client, &tls.Config{InsecureSkipVerify: true})
+			}
+			// closing the pipe itself, rather than the TLS connection, skips waiting to send close_notify
+			defer client.Close()
+			go s.handle(serverConn)
+
+			go func() {
+				clientConn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+			}()
+
+			clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			res, err := readTestResponse(t, bufio.NewReader(clientConn))
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+
+			assert.Equal(t, res.line, "HTTP/1.0 200 OK")
+			assert.Equal(t, res.body, tt.expected)
+		})
+	}
+}
+
+func TestServer_ServeTLS(t *testing.T) {
+	_, certPEM, keyPEM := newTestCertificate(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err := os.WriteFile(certFile, certPEM, 0o600)
+	if err == nil {
+		err = os.WriteFile(keyFile, keyPEM, 0o600)
+	}
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	s := &Server{
+		Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+			w.SetBody(fmt.Appendf(nil, "tls: %t", r.IsTLS()))
+		}),
+		ErrorLog: slog.New(slog.DiscardHandler),
+		Port:     port,
+	}
+	addr := fmt.Sprintf("localhost:%d", port)
+
+	go s.ServeTLS(certFile, keyFile)
+	defer s.Shutdown(context.Background())
+
+	var conn net.Conn
+	for range 50 {
+		conn, err = tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("could not connect to server: %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	res, err := readTestResponse(t, bufio.NewReader(conn))
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+	assert.Equal(t, res.body, "tls: true")
+}
+
+func TestServer_ServeTLSWithoutCertificate(t *testing.T) {
+	s := &Server{
+		Handler:  HandlerFunc(func(r Request, w *ResponseWriter) {}),
+		ErrorLog: slog.New(slog.DiscardHandler),
+	}
+
+	err := s.ServeTLS("", "")
+	assert.ErrorStatus(t, err, true)
+}
+
+// lateListener hands out conn from Accept only once release is closed, standing in for a connection accepted just as
+// the server shuts down.
+type lateListener struct {
+	conn    net.Conn
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (l *lateListener) Accept() (net.Conn, error) {
+	<-l.release
+	select {
+	case <-l.closed:
+		if l.conn == nil {
+			return nil, net.ErrClosed
+		}
+	default:
+	}
+
+	conn := l.conn
+	l.conn = nil
+	return conn, nil
+}
+
+func (l *lateListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *lateListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+func TestServer_ShutdownDuringAccept(t *testing.T) {
+	handled := make(chan struct{}, 1)
+	s := &Server{
+		Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+			handled <- struct{}{}
+		}),
+		ErrorLog: slog.New(slog.DiscardHandler),
+	}
+	err := s.init()
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	ln := &lateListener{conn: server, release: make(chan struct{}), closed: make(chan struct{})}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serve(ln)
+	}()
+
+	for range 50 {
+		s.state.mu.Lock()
+		listening := s.state.listener != nil
+		s.state.mu.Unlock()
+		if listening {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = s.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+	close(ln.release)
+
+	select {
+	case err := <-served:
+		assert.Equal(t, errors.Is(err, ErrServerClosed), true)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after Shutdown")
+	}
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	go client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	_, err = client.Read(make([]byte, 1))
+	assert.Equal(t, errors.Is(err, io.EOF), true)
+
+	select {
+	case <-handled:
+		t.Error("connection accepted after Shutdown was handled")
+	default:
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	s := &Server{}
+	state := s.initSync()
+	state.inFlight.Add(1)
+	defer state.inFlight.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := s.Shutdown(ctx)
+	assert.Equal(t, err, context.DeadlineExceeded)
+}
+
+func TestServer_ShutdownRightAfterServe(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	s := &Server{
+		Handler:  HandlerFunc(func(r Request, w *ResponseWriter) {}),
+		ErrorLog: slog.New(slog.DiscardHandler),
+		Port:     port,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = s.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	select {
+	case err := <-served:
+		assert.Equal(t, errors.Is(err, ErrServerClosed), true)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+}
+
+func TestServer_ShutdownClosesIdleConns(t *testing.T) {
+	idle := make(chan struct{}, 1)
+	s := &Server{
+		Handler: HandlerFunc(func(r Request, w *ResponseWriter) {
+			w.SetBody([]byte("hello"))
+		}),
+		ErrorLog:      slog.New(slog.DiscardHandler),
+		HeaderTimeout: 10000,
+		ConnState: func(c net.Conn, state ConnState) {
+			if state == StateIdle {
+				idle <- struct{}{}
+			}
+		},
+	}
+	err := s.init()
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Test could not complete! (%s)", err.Error())
+	}
+	go s.serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect to server: %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	res, err := readTestResponse(t, reader)
+	if err != nil {
+		t.Fatalf("could not read response: %s", err.Error())
+	}
+	assert.Equal(t, res.body, "hello")
+
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection did not go idle")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = s.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err.Error())
+	}
+
+	_, err = reader.ReadByte()
+	assert.Equal(t, errors.Is(err, io.EOF), true)
+}
+
+func TestServer_handleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		server   Server
+		expected string
+	}{
+		{
+			name:     "Header block exceeds header limit",
+			data:     []byte("GET / HTTP/1.0\r\nX-Long: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\r\n\r\n"),
+			server:   Server{MaxHeaderBytes: 32},
+			expected: "HTTP/1.0 413 Request Entity Too Large",
+		},
+		{
+			name:     "Malformed request line",
+			data:     []byte("GET /\r\n\r\n"),
+			expected: "HTTP/1.0 400 Bad Request",
+		},
+		{
+			name:     "Request uri not an absolute path",
+			data:     []byte("GET //example.com/ HTTP/1.0\r\n\r\n"),
+			expected: "HTTP/1.0 400 Bad Request",
+		},
+		{
+			name:     "Control character in header value",
+			data:     []byte("GET / HTTP/1.0\r\nX-Test: a\x01b\r\n\r\n"),
+			expected: "HTTP/1.0 400 Bad Request",
+		},
+		{
+			name:     "Body cannot be decoded",
+			data:     []byte("POST / HTTP/1.0\r\nContent-Encoding: gzip\r\nContent-Length: 5\r\n\r\nhello"),
+			expected: "HTTP/1.0 500 Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.server
+			s.Handler = HandlerFunc(func(r Request, w *ResponseWriter) {})
+			s.ErrorLog = slog.New(slog.DiscardHandler)
+			s.init()
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go s.handle(server)
+
+			go func() {
+				client.Write(tt.data)
+			}()
+
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			res, err := readTestResponse(t, bufio.NewReader(client))
+			if err != nil {
+				t.Fatalf("could not read response: %s", err.Error())
+			}
+
+			assert.Equal(t, res.line, tt.expected)
+			assert.Equal(t, len(res.body) > 0, true)
+		})
+	}
+}
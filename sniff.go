@@ -0,0 +1,101 @@
+package http
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// sniffLen is the number of leading bytes sniffContentType looks at.
+const sniffLen = 512
+
+type sniffSignature struct {
+	prefix      []byte
+	contentType ContentType
+}
+
+var sniffSignatures = []sniffSignature{
+	{[]byte("\x89PNG\r\n\x1a\n"), ContentType{Type: "image", Subtype: "png"}},
+	{[]byte("\xff\xd8\xff"), ContentType{Type: "image", Subtype: "jpeg"}},
+	{[]byte("GIF87a"), ContentType{Type: "image", Subtype: "gif"}},
+	{[]byte("GIF89a"), ContentType{Type: "image", Subtype: "gif"}},
+}
+
+var htmlTags = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<head"),
+	[]byte("<body"),
+	[]byte("<title"),
+	[]byte("<script"),
+	[]byte("<style"),
+	[]byte("<div"),
+	[]byte("<p"),
+	[]byte("<h1"),
+	[]byte("<a"),
+	[]byte("<br"),
+	[]byte("<table"),
+	[]byte("<!--"),
+}
+
+// sniffContentType guesses the media type of data from its first sniffLen bytes. It recognizes HTML, a handful of image
+// formats, and plain text, falling back to application/octet-stream.
+func sniffContentType(data []byte) ContentType {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+
+	for _, sig := range sniffSignatures {
+		if bytes.HasPrefix(data, sig.prefix) {
+			return sig.contentType
+		}
+	}
+
+	if isHtml(data) {
+		return ContentType{Type: "text", Subtype: "html", Parameters: map[string]string{"charset": "utf-8"}}
+	}
+
+	if isText(data) {
+		return ContentType{Type: "text", Subtype: "plain", Parameters: map[string]string{"charset": "utf-8"}}
+	}
+
+	return ContentType{Type: "application", Subtype: "octet-stream"}
+}
+
+func isHtml(data []byte) bool {
+	data = bytes.TrimLeft(data, "\t\n\f\r ")
+
+	for _, tag := range htmlTags {
+		if len(data) <= len(tag) || !bytes.EqualFold(data[:len(tag)], tag) {
+			continue
+		}
+
+		// the tag must end here, so <p> matches but <pre> is not mistaken for it
+		next := data[len(tag)]
+		if next == ' ' || next == '>' || tag[1] == '!' {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isText(data []byte) bool {
+	for len(data) > 0 {
+		// a multi-byte rune cut short by the sniffLen limit is not evidence of binary data
+		if !utf8.FullRune(data) {
+			break
+		}
+
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			return false
+		}
+		if r < ' ' && r != '\t' && r != '\n' && r != '\f' && r != '\r' && r != 0x1b {
+			return false
+		}
+
+		data = data[size:]
+	}
+
+	return true
+}
@@ -1,21 +1,29 @@
 package http
 
 const (
-	StatusOK                  = 200
-	StatusCreated             = 201
-	StatusAccepted            = 202
-	StatusNoContent           = 204
-	StatusMovedPermanently    = 301
-	StatusMovedTemporarily    = 302
-	StatusNotModified         = 304
-	StatusBadRequest          = 400
-	StatusUnauthorized        = 401
-	StatusForbidden           = 403
-	StatusNotFound            = 404
-	StatusInternalServerError = 500
-	StatusNotImplemented      = 501
-	StatusBadGateway          = 502
-	StatusServiceUnavailable  = 503
+	StatusOK                           = 200
+	StatusCreated                      = 201
+	StatusAccepted                     = 202
+	StatusNoContent                    = 204
+	StatusPartialContent               = 206
+	StatusMovedPermanently             = 301
+	StatusMovedTemporarily             = 302
+	StatusSeeOther                     = 303
+	StatusNotModified                  = 304
+	StatusBadRequest                   = 400
+	StatusUnauthorized                 = 401
+	StatusForbidden                    = 403
+	StatusNotFound                     = 404
+	StatusMethodNotAllowed             = 405
+	StatusRequestTimeout               = 408
+	StatusPreconditionFailed           = 412
+	StatusRequestEntityTooLarge        = 413
+	StatusRequestURITooLong            = 414
+	StatusRequestedRangeNotSatisfiable = 416
+	StatusInternalServerError          = 500
+	StatusNotImplemented               = 501
+	StatusBadGateway                   = 502
+	StatusServiceUnavailable           = 503
 )
 
 func StatusText(code int) string {
@@ -28,10 +36,14 @@ func StatusText(code int) string {
 		return "Accepted"
 	case StatusNoContent:
 		return "No Content"
+	case StatusPartialContent:
+		return "Partial Content"
 	case StatusMovedPermanently:
 		return "Moved Permanently"
 	case StatusMovedTemporarily:
 		return "Moved Temporarily"
+	case StatusSeeOther:
+		return "See Other"
 	case StatusNotModified:
 		return "Not Modified"
 	case StatusBadRequest:
@@ -42,6 +54,18 @@ func StatusText(code int) string {
 		return "Forbidden"
 	case StatusNotFound:
 		return "Not Found"
+	case StatusMethodNotAllowed:
+		return "Method Not Allowed"
+	case StatusRequestTimeout:
+		return "Request Timeout"
+	case StatusPreconditionFailed:
+		return "Precondition Failed"
+	case StatusRequestEntityTooLarge:
+		return "Request Entity Too Large"
+	case StatusRequestURITooLong:
+		return "Request-URI Too Long"
+	case StatusRequestedRangeNotSatisfiable:
+		return "Requested Range Not Satisfiable"
 	case StatusInternalServerError:
 		return "Internal Server Error"
 	case StatusNotImplemented:
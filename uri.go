@@ -11,6 +11,7 @@ import (
 type Uri interface {
 	GetPath() []byte
 	marshal() []byte
+	marshalEncoded() []byte
 }
 
 func unescapeSequence(data []byte, i int) (byte, error) {
@@ -46,6 +47,26 @@ func parseUri(data []byte) (Uri, error) {
 	return uri, err
 }
 
+// parseUriWithFragment is like parseUri, but tolerates a trailing fragment on an absolute URI.
+func parseUriWithFragment(data []byte) (Uri, error) {
+	if validateStartsWithScheme(data) == nil {
+		return parseAbsoluteUriWithFragment(data)
+	}
+
+	return parseRelativeUri(data)
+}
+
+func cloneUri(u Uri) Uri {
+	switch u := u.(type) {
+	case AbsoluteUri:
+		return u.clone()
+	case RelativeUri:
+		return u.clone()
+	default:
+		return u
+	}
+}
+
 func validateStartsWithScheme(data []byte) error {
 	colonIndex := bytes.Index(data, []byte{':'})
 	if colonIndex == -1 {
@@ -57,8 +78,13 @@ func validateStartsWithScheme(data []byte) error {
 }
 
 type AbsoluteUri struct {
-	Scheme []byte
-	Path   []byte
+	Scheme   []byte
+	Path     []byte
+	Fragment []byte
+}
+
+func (u AbsoluteUri) clone() AbsoluteUri {
+	return AbsoluteUri{Scheme: bytes.Clone(u.Scheme), Path: bytes.Clone(u.Path), Fragment: bytes.Clone(u.Fragment)}
 }
 
 func (u AbsoluteUri) GetPath() []byte {
@@ -66,6 +92,16 @@ func (u AbsoluteUri) GetPath() []byte {
 }
 
 func parseAbsoluteUri(data []byte) (AbsoluteUri, error) {
+	return parseAbsoluteUriFragment(data, false)
+}
+
+// parseAbsoluteUriWithFragment is like parseAbsoluteUri, but accepts a trailing "#fragment", which RFC 1945
+// excludes from absoluteURI but which shows up in headers like Referer.
+func parseAbsoluteUriWithFragment(data []byte) (AbsoluteUri, error) {
+	return parseAbsoluteUriFragment(data, true)
+}
+
+func parseAbsoluteUriFragment(data []byte, allowFragment bool) (AbsoluteUri, error) {
 	var uri AbsoluteUri
 
 	err := validateStartsWithScheme(data)
@@ -76,6 +112,19 @@ func parseAbsoluteUri(data []byte) (AbsoluteUri, error) {
 	scheme, remaining, _ := bytes.Cut(data, []byte{':'})
 	uri.Scheme = scheme
 
+	if allowFragment {
+		var fragment []byte
+		var found bool
+		remaining, fragment, found = bytes.Cut(remaining, []byte{'#'})
+
+		if found {
+			uri.Fragment, err = parseUriQuery(fragment)
+			if err != nil {
+				return uri, fmt.Errorf("invalid fragment: %s", err)
+			}
+		}
+	}
+
 	var path []byte
 	i := 0
 
@@ -111,10 +160,85 @@ type RelativeUri struct {
 	Query  []byte
 }
 
+func (u RelativeUri) clone() RelativeUri {
+	clone := RelativeUri{NetLoc: bytes.Clone(u.NetLoc), Path: bytes.Clone(u.Path), Query: bytes.Clone(u.Query)}
+	if u.Params != nil {
+		clone.Params = make([][]byte, len(u.Params))
+		for i, param := range u.Params {
+			clone.Params[i] = bytes.Clone(param)
+		}
+	}
+
+	return clone
+}
+
 func (u RelativeUri) GetPath() []byte {
 	return u.marshal()
 }
 
+// Host returns the host named by NetLoc, without its port. IPv6 literals keep their brackets, as in "[::1]". When
+// NetLoc is not a valid host and port, it is returned whole.
+func (u RelativeUri) Host() string {
+	host, _, err := parseHostPort(string(u.NetLoc))
+	if err != nil {
+		return string(u.NetLoc)
+	}
+
+	return host
+}
+
+// Port returns the port named by NetLoc, and false when it names none, or NetLoc is not a valid host and port.
+func (u RelativeUri) Port() (int, bool) {
+	_, port, err := parseHostPort(string(u.NetLoc))
+	if err != nil {
+		return 0, false
+	}
+
+	return port, port > 0
+}
+
+// QueryValues splits the query into its name/value pairs, on & and then the first =, decoding any escape sequences in
+// either side. A name without an = has the empty value. Names that appear more than once keep every value, in the order
+// they were sent.
+func (u RelativeUri) QueryValues() map[string][]string {
+	values := make(map[string][]string)
+
+	for pair := range bytes.SplitSeq(u.Query, []byte{'&'}) {
+		if len(pair) == 0 {
+			continue
+		}
+
+		name, value, _ := bytes.Cut(pair, []byte{'='})
+		n := unescapeQueryComponent(name)
+		values[n] = append(values[n], unescapeQueryComponent(value))
+	}
+
+	return values
+}
+
+// unescapeQueryComponent decodes the escape sequences in data. Unlike form values, + is not treated as a space. A
+// malformed escape sequence is kept as it is.
+func unescapeQueryComponent(data []byte) string {
+	var res []byte
+	i := 0
+
+	for i < len(data) {
+		if constructs.HttpByte(data[i]).IsEscape() {
+			c, err := unescapeSequence(data, i)
+			if err == nil {
+				res = append(res, c)
+				i += 3
+				continue
+			}
+		}
+
+		res = append(res, data[i])
+		i++
+	}
+
+	return string(res)
+}
+
 const (
 	NetPath = "net_path"
 	AbsPath = "abs_path"
@@ -303,6 +427,8 @@ func parseUriParams(data []byte) ([][]byte, error) {
 	return params, nil
 }
 
+// parseUriQuery validates a query, decoding its escape sequences. Escaped reserved bytes, and escaped %, are kept as they
+// were sent, since decoding them would change how the query splits into components.
 func parseUriQuery(data []byte) ([]byte, error) {
 	var query []byte
 	i := 0
@@ -315,8 +441,16 @@ func parseUriQuery(data []byte) ([]byte, error) {
 			if err != nil {
 				return query, err
 			}
+
+			decoded := constructs.HttpByte(c)
+			if decoded.IsReserved() || decoded.IsEscape() {
+				query = append(query, data[i:i+3]...)
+				i += 3
+				continue
+			}
+
 			i += 3
-			b = constructs.HttpByte(c)
+			b = decoded
 		} else {
 			i++
 		}
@@ -338,6 +338,85 @@ func TestParseAbsoluteUri(t *testing.T) {
 	}
 }
 
+func TestParseAbsoluteUriWithFragment(t *testing.T) {
+	tests := []struct {
+		name          string
+		uri           []byte
+		allowFragment bool
+		expected      AbsoluteUri
+		expectError   bool
+	}{
+		{
+			name:          "Strict without fragment",
+			uri:           []byte("http://example.com/index.html"),
+			allowFragment: false,
+			expected: AbsoluteUri{
+				Scheme: []byte("http"),
+				Path:   []byte("//example.com/index.html"),
+			},
+			expectError: false,
+		},
+		{
+			name:          "Strict with fragment",
+			uri:           []byte("http://example.com/index.html#top"),
+			allowFragment: false,
+			expectError:   true,
+		},
+		{
+			name:          "Lenient without fragment",
+			uri:           []byte("http://example.com/index.html"),
+			allowFragment: true,
+			expected: AbsoluteUri{
+				Scheme: []byte("http"),
+				Path:   []byte("//example.com/index.html"),
+			},
+			expectError: false,
+		},
+		{
+			name:          "Lenient with fragment",
+			uri:           []byte("http://example.com/index.html?a=1#sec%2F2"),
+			allowFragment: true,
+			expected: AbsoluteUri{
+				Scheme:   []byte("http"),
+				Path:     []byte("//example.com/index.html?a=1"),
+				Fragment: []byte("sec%2F2"),
+			},
+			expectError: false,
+		},
+		{
+			name:          "Lenient with empty fragment",
+			uri:           []byte("http://example.com/#"),
+			allowFragment: true,
+			expected: AbsoluteUri{
+				Scheme: []byte("http"),
+				Path:   []byte("//example.com/"),
+			},
+			expectError: false,
+		},
+		{
+			name:          "Lenient with second fragment marker",
+			uri:           []byte("http://example.com/#a#b"),
+			allowFragment: true,
+			expectError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseAbsoluteUriFragment(tt.uri, tt.allowFragment)
+
+			ok := assert.ErrorStatus(t, err, tt.expectError)
+			if !ok {
+				return
+			}
+
+			assert.SliceEqual(t, res.Scheme, tt.expected.Scheme)
+			assert.SliceEqual(t, res.Path, tt.expected.Path)
+			assert.SliceEqual(t, res.Fragment, tt.expected.Fragment)
+		})
+	}
+}
+
 func TestParseRelativeUri(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -776,6 +855,18 @@ func TestParseUriQuery(t *testing.T) {
 			expected:    []byte("info={test}"),
 			expectError: false,
 		},
+		{
+			name:        "Escaped reserved bytes are kept (?a=%3D%26b)",
+			query:       []byte("a=%3D%26b"),
+			expected:    []byte("a=%3D%26b"),
+			expectError: false,
+		},
+		{
+			name:        "Escaped % is kept (?a=100%25)",
+			query:       []byte("a=100%25"),
+			expected:    []byte("a=100%25"),
+			expectError: false,
+		},
 		{
 			name:        "Non-hex escape param (?info=te%XDst)",
 			query:       []byte("info=te%XDst"),
@@ -811,3 +902,156 @@ func TestParseUriQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestRelativeUri_QueryValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      []byte
+		expected map[string][]string
+	}{
+		{
+			name: "Single pair",
+			uri:  []byte("/search?q=go"),
+			expected: map[string][]string{
+				"q": {"go"},
+			},
+		},
+		{
+			name: "Repeated keys",
+			uri:  []byte("/search?tag=a&tag=b&page=2"),
+			expected: map[string][]string{
+				"tag":  {"a", "b"},
+				"page": {"2"},
+			},
+		},
+		{
+			name: "Key with no value",
+			uri:  []byte("/search?debug&q="),
+			expected: map[string][]string{
+				"debug": {""},
+				"q":     {""},
+			},
+		},
+		{
+			name: "Encoded = and & inside a value",
+			uri:  []byte("/search?expr=a%3Db%26c&x=1"),
+			expected: map[string][]string{
+				"expr": {"a=b&c"},
+				"x":    {"1"},
+			},
+		},
+		{
+			name: "Encoded name and literal +",
+			uri:  []byte("/search?%61b=1+2&pct=100%25"),
+			expected: map[string][]string{
+				"ab":  {"1+2"},
+				"pct": {"100%"},
+			},
+		},
+		{
+			name:     "No query",
+			uri:      []byte("/search"),
+			expected: map[string][]string{},
+		},
+		{
+			name: "Empty pairs are skipped",
+			uri:  []byte("/search?&a=1&&"),
+			expected: map[string][]string{
+				"a": {"1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, err := parseRelativeUri(tt.uri)
+			if err != nil {
+				t.Fatalf("Test could not complete! (%s)", err.Error())
+			}
+
+			res := uri.QueryValues()
+			assert.Equal(t, len(res), len(tt.expected))
+			for name, values := range tt.expected {
+				assert.SliceEqual(t, res[name], values)
+			}
+		})
+	}
+}
+
+func TestRelativeUri_HostPort(t *testing.T) {
+	tests := []struct {
+		name         string
+		netLoc       string
+		expectedHost string
+		expectedPort int
+		expectedOk   bool
+	}{
+		{
+			name:         "Host and port",
+			netLoc:       "localhost:8080",
+			expectedHost: "localhost",
+			expectedPort: 8080,
+			expectedOk:   true,
+		},
+		{
+			name:         "Bare host",
+			netLoc:       "example.com",
+			expectedHost: "example.com",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+		{
+			name:         "IPv6 literal with port",
+			netLoc:       "[::1]:80",
+			expectedHost: "[::1]",
+			expectedPort: 80,
+			expectedOk:   true,
+		},
+		{
+			name:         "IPv6 literal without port",
+			netLoc:       "[2001:db8::1]",
+			expectedHost: "[2001:db8::1]",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+		{
+			name:         "Empty port",
+			netLoc:       "example.com:",
+			expectedHost: "example.com",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+		{
+			name:         "Port out of range",
+			netLoc:       "example.com:65536",
+			expectedHost: "example.com:65536",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+		{
+			name:         "Non-numeric port",
+			netLoc:       "example.com:http",
+			expectedHost: "example.com:http",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+		{
+			name:         "No net_loc",
+			netLoc:       "",
+			expectedHost: "",
+			expectedPort: 0,
+			expectedOk:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := RelativeUri{NetLoc: []byte(tt.netLoc)}
+
+			assert.Equal(t, uri.Host(), tt.expectedHost)
+			port, ok := uri.Port()
+			assert.Equal(t, port, tt.expectedPort)
+			assert.Equal(t, ok, tt.expectedOk)
+		})
+	}
+}